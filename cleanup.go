@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/registry"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// defaultCleanupPaths are the folders Floorp creates on the host. They are
+// only removed if they did not exist before the launch, as they may belong to
+// a Floorp installed on the host.
+var defaultCleanupPaths = []string{
+	`${APPDATA}\Floorp`,
+	`${LOCALAPPDATA}\Floorp`,
+	`${USERPROFILE}\AppData\LocalLow\Floorp`,
+	`${USERPROFILE}\AppData\Roaming\Floorp`,
+}
+
+// defaultCleanupRegistry are the registry keys Floorp creates on the host,
+// removed under the same condition as defaultCleanupPaths.
+var defaultCleanupRegistry = []string{
+	`HKCU\Software\Floorp`,
+}
+
+// hostTempDir is the temp folder of the host, before the strict portable mode
+// redirects it.
+var hostTempDir = os.TempDir()
+
+// hostTraceLocation is a host folder scanned for traces left by Floorp.
+type hostTraceLocation struct {
+	Category string
+	Folder   string
+	Match    func(name string) bool
+}
+
+// hostTrace is an entry of a host folder.
+type hostTrace struct {
+	Category string
+	Path     string
+	ModTime  time.Time
+}
+
+// hostTraces is a snapshot of the traces present on the host. Existing holds
+// the default cleanup paths and registry keys present before the launch.
+type hostTraces struct {
+	Entries  map[string]hostTrace
+	Existing map[string]bool
+}
+
+// cleanupResult lists what the cleanup removed, or would remove in dry-run mode.
+type cleanupResult struct {
+	DryRun    bool
+	Removed   []string
+	Failed    []string
+	Leftovers []hostTrace
+}
+
+// hostTraceLocations returns the host folders where Floorp may leave traces.
+func hostTraceLocations() []hostTraceLocation {
+	all := func(string) bool { return true }
+	mozilla := func(name string) bool {
+		name = strings.ToLower(name)
+		return strings.HasPrefix(name, "mozilla") || strings.Contains(name, "floorp") || strings.HasPrefix(name, "tmpaddon")
+	}
+	dumps := func(name string) bool {
+		name = strings.ToLower(name)
+		return strings.HasPrefix(name, "floorp") && strings.HasSuffix(name, ".dmp")
+	}
+
+	return []hostTraceLocation{
+		{"temp", hostTempDir, mozilla},
+		{"mozilla", os.ExpandEnv(`${APPDATA}\Mozilla`), all},
+		{"mozilla", os.ExpandEnv(`${LOCALAPPDATA}\Mozilla`), all},
+		{"floorp", os.ExpandEnv(`${APPDATA}\Floorp`), all},
+		{"floorp", os.ExpandEnv(`${LOCALAPPDATA}\Floorp`), all},
+		{"crash", os.ExpandEnv(`${LOCALAPPDATA}\CrashDumps`), dumps},
+	}
+}
+
+// snapshotHostTraces lists the traces present on the host before the launch.
+func snapshotHostTraces() *hostTraces {
+	existing := map[string]bool{}
+	for _, pattern := range defaultCleanupPaths {
+		matches, _ := filepath.Glob(os.ExpandEnv(pattern))
+		for _, match := range matches {
+			existing[match] = true
+		}
+	}
+	for _, key := range defaultCleanupRegistry {
+		regKey := registry.Key{Key: key, Arch: "64"}
+		if regKey.Exists() {
+			existing[key] = true
+		}
+	}
+
+	return &hostTraces{
+		Entries:  scanHostTraces(),
+		Existing: existing,
+	}
+}
+
+// preexisting reports whether a default cleanup path or registry key existed
+// before the launch. Without a snapshot, every default is kept.
+func (t *hostTraces) preexisting(name string) bool {
+	return t == nil || t.Existing[name]
+}
+
+// scanHostTraces lists the entries of the host trace locations.
+func scanHostTraces() map[string]hostTrace {
+	traces := map[string]hostTrace{}
+	for _, location := range hostTraceLocations() {
+		if !filepath.IsAbs(location.Folder) {
+			continue
+		}
+		entries, err := os.ReadDir(location.Folder)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !location.Match(entry.Name()) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			tracePath := filepath.Join(location.Folder, entry.Name())
+			traces[tracePath] = hostTrace{
+				Category: location.Category,
+				Path:     tracePath,
+				ModTime:  info.ModTime(),
+			}
+		}
+	}
+	return traces
+}
+
+// leftovers returns the traces created or modified since the snapshot.
+func (t *hostTraces) leftovers() []hostTrace {
+	var leftovers []hostTrace
+	for tracePath, trace := range scanHostTraces() {
+		if before, ok := t.Entries[tracePath]; ok && !trace.ModTime.After(before.ModTime) {
+			continue
+		}
+		leftovers = append(leftovers, trace)
+	}
+	sort.Slice(leftovers, func(i, j int) bool {
+		return leftovers[i].Path < leftovers[j].Path
+	})
+	return leftovers
+}
+
+// cleanupPaths returns the existing paths to remove. Default paths present
+// before the launch are kept.
+func cleanupPaths(traces *hostTraces) []string {
+	var paths []string
+	for _, pattern := range defaultCleanupPaths {
+		for _, match := range expandCleanupPath(pattern) {
+			if traces.preexisting(match) {
+				log.Info().Msgf("Keeping %s, it existed before the launch", match)
+				continue
+			}
+			paths = append(paths, match)
+		}
+	}
+	for _, pattern := range cfg.CleanupPaths {
+		paths = append(paths, expandCleanupPath(pattern)...)
+	}
+	return paths
+}
+
+// expandCleanupPath returns the existing paths matching a cleanup path, with
+// environment variables and glob patterns expanded. Invalid paths are skipped.
+func expandCleanupPath(pattern string) []string {
+	expanded := os.ExpandEnv(pattern)
+	if err := checkCleanupPath(expanded); err != nil {
+		log.Error().Err(err).Msgf("Invalid cleanup path %s", pattern)
+		return nil
+	}
+	matches, err := filepath.Glob(expanded)
+	if err != nil {
+		log.Error().Err(err).Msgf("Invalid cleanup path %s", pattern)
+		return nil
+	}
+	return matches
+}
+
+// cleanupRegistryKeys returns the registry keys to remove. Default keys
+// present before the launch are kept and invalid keys are skipped.
+func cleanupRegistryKeys(traces *hostTraces) []string {
+	var keys []string
+	for _, key := range defaultCleanupRegistry {
+		if traces.preexisting(key) {
+			log.Info().Msgf("Keeping registry key %s, it existed before the launch", key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	for _, key := range cfg.CleanupRegistry {
+		if err := checkCleanupRegistryKey(key); err != nil {
+			log.Error().Err(err).Msgf("Invalid cleanup registry key %s", key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// checkCleanupRegistryKey only allows subkeys of HKCU\Software, so an entry
+// cannot delete a hive or a key of the machine.
+func checkCleanupRegistryKey(key string) error {
+	segments := strings.Split(key, `\`)
+	if len(segments) < 3 ||
+		!(strings.EqualFold(segments[0], "HKCU") || strings.EqualFold(segments[0], "HKEY_CURRENT_USER")) ||
+		!strings.EqualFold(segments[1], "Software") {
+		return errors.New(`key must be a subkey of HKCU\Software`)
+	}
+	for _, segment := range segments[2:] {
+		if strings.TrimSpace(segment) == "" {
+			return errors.New("key has an empty segment")
+		}
+	}
+	return nil
+}
+
+// checkCleanupPath refuses paths that could wipe more than a folder of the
+// host, e.g. when an environment variable is not defined.
+func checkCleanupPath(cleanupPath string) error {
+	if !filepath.IsAbs(cleanupPath) {
+		return errors.New("path must be absolute")
+	}
+	cleaned := filepath.Clean(cleanupPath)
+	if len(strings.FieldsFunc(strings.TrimPrefix(cleaned, filepath.VolumeName(cleaned)), isPathSeparator)) < 2 {
+		return errors.New("path is too close to the root of the drive")
+	}
+	if isSubPath(cleaned, app.RootPath) || isSubPath(app.RootPath, cleaned) {
+		return errors.New("path overlaps the portable folder")
+	}
+	return nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '\\' || r == '/'
+}
+
+// cleanupHost removes the traces Floorp left on the host and reports the
+// leftovers created since the snapshot. In dry-run mode nothing is removed.
+func cleanupHost(traces *hostTraces) *cleanupResult {
+	result := &cleanupResult{DryRun: cfg.CleanupDryRun}
+
+	if cfg.Cleanup {
+		for _, cleanupPath := range cleanupPaths(traces) {
+			if result.DryRun {
+				log.Info().Msgf("Would remove %s", cleanupPath)
+				result.Removed = append(result.Removed, cleanupPath)
+				continue
+			}
+			log.Info().Msgf("Removing %s", cleanupPath)
+			if err := os.RemoveAll(cleanupPath); err != nil {
+				log.Error().Err(err).Msgf("Cannot cleanup %s", cleanupPath)
+				result.Failed = append(result.Failed, cleanupPath)
+				continue
+			}
+			result.Removed = append(result.Removed, cleanupPath)
+		}
+
+		for _, key := range cleanupRegistryKeys(traces) {
+			regKey := registry.Key{Key: key, Arch: "64"}
+			if !regKey.Exists() {
+				continue
+			}
+			if result.DryRun {
+				log.Info().Msgf("Would remove registry key %s", key)
+				result.Removed = append(result.Removed, key)
+				continue
+			}
+			log.Info().Msgf("Removing registry key %s", key)
+			if err := regKey.Delete(true); err != nil {
+				log.Error().Err(err).Msgf("Cannot cleanup registry key %s", key)
+				result.Failed = append(result.Failed, key)
+				continue
+			}
+			result.Removed = append(result.Removed, key)
+		}
+	}
+
+	if cfg.CleanupReport && traces != nil {
+		result.Leftovers = traces.leftovers()
+		for _, leftover := range result.Leftovers {
+			log.Warn().Msgf("Leftover %s on host: %s", leftover.Category, leftover.Path)
+		}
+	}
+	if cfg.CleanupReport || result.DryRun {
+		reportFile := utl.PathJoin(app.DataPath, "cleanup-report.txt")
+		if err := os.WriteFile(reportFile, []byte(result.String()), 0644); err != nil {
+			log.Error().Err(err).Msg("Cannot write cleanup report")
+		}
+	}
+
+	return result
+}
+
+// String returns the cleanup report.
+func (r *cleanupResult) String() string {
+	var b strings.Builder
+
+	removed := "Removed"
+	if r.DryRun {
+		removed = "Would remove (dry run)"
+	}
+	sections := []struct {
+		title   string
+		entries []string
+	}{
+		{removed, r.Removed},
+		{"Failed to remove", r.Failed},
+	}
+
+	fmt.Fprintf(&b, "Cleanup report of %s\n", time.Now().Format(time.RFC3339))
+	for _, section := range sections {
+		fmt.Fprintf(&b, "\n%s:\n", section.title)
+		if len(section.entries) == 0 {
+			fmt.Fprintln(&b, "  none")
+		}
+		for _, entry := range section.entries {
+			fmt.Fprintf(&b, "  %s\n", entry)
+		}
+	}
+
+	fmt.Fprintln(&b, "\nLeftovers created on the host during the run:")
+	if len(r.Leftovers) == 0 {
+		fmt.Fprintln(&b, "  none")
+	}
+	for _, leftover := range r.Leftovers {
+		fmt.Fprintf(&b, "  %-8s %s\n", leftover.Category, leftover.Path)
+	}
+
+	return b.String()
+}
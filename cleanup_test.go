@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeHost points the host folders to a temporary home and restores the
+// cleanup settings when the test ends.
+func fakeHost(t *testing.T) string {
+	home := t.TempDir()
+	for name, folder := range map[string]string{
+		"APPDATA":      filepath.Join(home, "AppData", "Roaming"),
+		"LOCALAPPDATA": filepath.Join(home, "AppData", "Local"),
+		"USERPROFILE":  home,
+	} {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(name, folder)
+	}
+
+	paths, registry, tempDir := defaultCleanupPaths, defaultCleanupRegistry, hostTempDir
+	cleanupPaths, cleanupRegistry, rootPath := cfg.CleanupPaths, cfg.CleanupRegistry, app.RootPath
+	t.Cleanup(func() {
+		defaultCleanupPaths, defaultCleanupRegistry, hostTempDir = paths, registry, tempDir
+		cfg.CleanupPaths, cfg.CleanupRegistry, app.RootPath = cleanupPaths, cleanupRegistry, rootPath
+	})
+
+	defaultCleanupPaths = []string{
+		filepath.Join("${APPDATA}", "Floorp"),
+		filepath.Join("${LOCALAPPDATA}", "Floorp"),
+	}
+	defaultCleanupRegistry = nil
+	hostTempDir = filepath.Join(home, "AppData", "Local", "Temp")
+	cfg.CleanupPaths = nil
+	cfg.CleanupRegistry = nil
+	app.RootPath = filepath.Join(home, "Portable")
+
+	return home
+}
+
+func mkdirs(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckCleanupPath(t *testing.T) {
+	home := fakeHost(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"folder", filepath.Join(home, "AppData", "Local", "Floorp"), false},
+		{"relative", filepath.Join("AppData", "Floorp"), true},
+		{"root", filepath.VolumeName(home) + string(filepath.Separator), true},
+		{"undefined variable", os.ExpandEnv(filepath.Join(filepath.VolumeName(home)+string(filepath.Separator), "${UNDEFINED_FLOORP_VAR}", "Floorp")), true},
+		{"portable folder", app.RootPath, true},
+		{"inside portable folder", filepath.Join(app.RootPath, "data"), true},
+		{"parent of portable folder", home, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkCleanupPath(tt.path); (err != nil) != tt.wantErr {
+				t.Errorf("checkCleanupPath(%q) = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCleanupRegistryKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{`HKCU\Software\Floorp`, false},
+		{`HKEY_CURRENT_USER\Software\Mozilla\Floorp`, false},
+		{`hkcu\software\floorp`, false},
+		{`HKCU`, true},
+		{`HKCU\Software`, true},
+		{`HKCU\Software\`, true},
+		{`HKCU\Software\\Floorp`, true},
+		{`HKCU\Environment`, true},
+		{`HKLM\Software\Floorp`, true},
+		{`HKEY_LOCAL_MACHINE`, true},
+		{``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := checkCleanupRegistryKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("checkCleanupRegistryKey(%q) = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCleanupPaths(t *testing.T) {
+	home := fakeHost(t)
+	roaming := filepath.Join(home, "AppData", "Roaming", "Floorp")
+	local := filepath.Join(home, "AppData", "Local", "Floorp")
+	temp := filepath.Join(home, "AppData", "Local", "Temp")
+
+	// A Floorp installed on the host owns the roaming folder
+	mkdirs(t, roaming, temp)
+	traces := snapshotHostTraces()
+	mkdirs(t, local, filepath.Join(temp, "mozilla-temp-1"), filepath.Join(temp, "mozilla-temp-2"), filepath.Join(temp, "other"))
+
+	cfg.CleanupPaths = []string{
+		filepath.Join("${LOCALAPPDATA}", "Temp", "mozilla-temp-*"),
+		filepath.Join("${UNDEFINED_FLOORP_VAR}", "Floorp"),
+		filepath.Join(app.RootPath, "data"),
+	}
+
+	got := cleanupPaths(traces)
+	want := []string{
+		local,
+		filepath.Join(temp, "mozilla-temp-1"),
+		filepath.Join(temp, "mozilla-temp-2"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cleanupPaths = %q, want %q", got, want)
+	}
+
+	if got := cleanupPaths(nil); len(got) != 2 {
+		t.Errorf("cleanupPaths without snapshot = %q, want only the configured paths", got)
+	}
+}
+
+func TestCleanupRegistryKeys(t *testing.T) {
+	fakeHost(t)
+	defaultCleanupRegistry = []string{`HKCU\Software\Floorp`}
+	cfg.CleanupRegistry = []string{`HKCU\Software\Mozilla\Floorp`, `HKCU`, `HKLM\Software\Floorp`}
+
+	tests := []struct {
+		name   string
+		traces *hostTraces
+		want   []string
+	}{
+		{
+			name:   "default key absent before launch",
+			traces: &hostTraces{Existing: map[string]bool{}},
+			want:   []string{`HKCU\Software\Floorp`, `HKCU\Software\Mozilla\Floorp`},
+		},
+		{
+			name:   "default key of installed Floorp",
+			traces: &hostTraces{Existing: map[string]bool{`HKCU\Software\Floorp`: true}},
+			want:   []string{`HKCU\Software\Mozilla\Floorp`},
+		},
+		{
+			name: "no snapshot",
+			want: []string{`HKCU\Software\Mozilla\Floorp`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanupRegistryKeys(tt.traces); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cleanupRegistryKeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeftovers(t *testing.T) {
+	home := fakeHost(t)
+	temp := filepath.Join(home, "AppData", "Local", "Temp")
+	old := filepath.Join(temp, "mozilla-old")
+	mkdirs(t, old, filepath.Join(temp, "mozilla-unchanged"))
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	traces := snapshotHostTraces()
+	mkdirs(t, filepath.Join(temp, "mozilla-temp-1"), filepath.Join(temp, "tmpaddon-1"), filepath.Join(temp, "unrelated"))
+	if err := os.Chtimes(old, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, leftover := range traces.leftovers() {
+		if leftover.Category != "temp" {
+			t.Errorf("leftover %s has category %s", leftover.Path, leftover.Category)
+		}
+		got = append(got, leftover.Path)
+	}
+	want := []string{old, filepath.Join(temp, "mozilla-temp-1"), filepath.Join(temp, "tmpaddon-1")}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("leftovers = %q, want %q", got, want)
+	}
+}
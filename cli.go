@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// launcherFlags holds the command-line flags consumed by the launcher itself.
+// They are stripped from the arguments passed to Floorp.
+type launcherFlags struct {
+	Profile        string
+	Preset         string
+	PrintPolicies  bool
+	ListSessions   bool
+	CrashReport    bool
+	Diagnose       bool
+	Verify         bool
+	ExportSession  string
+	RestoreSession string
+	Format         string
+	Output         string
+}
+
+// parseLauncherFlags extracts launcher flags from args and returns them along
+// with the remaining arguments meant for Floorp. Flags taking a value accept
+// both --flag value and --flag=value.
+func parseLauncherFlags(args []string) (launcherFlags, []string) {
+	var flags launcherFlags
+	boolFlags := map[string]*bool{
+		"--print-policies": &flags.PrintPolicies,
+		"--list-sessions":  &flags.ListSessions,
+		"--crash-report":   &flags.CrashReport,
+		"--diagnose":       &flags.Diagnose,
+		"--verify":         &flags.Verify,
+	}
+	valueFlags := map[string]*string{
+		"--portable-profile": &flags.Profile,
+		"--preset":           &flags.Preset,
+		"--export-session":   &flags.ExportSession,
+		"--restore-session":  &flags.RestoreSession,
+		"--format":           &flags.Format,
+		"--output":           &flags.Output,
+	}
+
+	remaining := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if target, ok := boolFlags[args[i]]; ok {
+			*target = true
+			continue
+		}
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if target, ok := valueFlags[name]; ok {
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			*target = value
+			continue
+		}
+		remaining = append(remaining, args[i])
+	}
+
+	return flags, remaining
+}
+
+// attachConsole binds stdout and stderr to the console of the parent process
+// (e.g. cmd.exe) so launcher commands can print their output. The launcher is
+// a GUI executable and has no console of its own. Redirected handles are kept.
+func attachConsole() {
+	if handle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err == nil && handle != 0 && handle != windows.InvalidHandle {
+		return
+	}
+
+	const attachParentProcess = ^uint32(0)
+	ret, _, _ := windows.NewLazySystemDLL("kernel32.dll").NewProc("AttachConsole").Call(uintptr(attachParentProcess))
+	if ret == 0 {
+		return
+	}
+
+	conout, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	os.Stdout = conout
+	os.Stderr = conout
+}
@@ -0,0 +1,186 @@
+// Command mozlz4 inspects and edits the mozLz4 files of a Floorp profile
+// (sessionstore.jsonlz4, search.json.mozlz4, addonStartup.json.lz4...).
+//
+// Usage:
+//
+//	mozlz4 decompress [-o output] file
+//	mozlz4 pretty [-o output] file
+//	mozlz4 compress [-o output] file
+//	mozlz4 verify file...
+//
+// Output defaults to stdout, -o can also follow the file. Use - as file to
+// read from stdin.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/mozlz4"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "decompress", "d":
+		err = convert(cmd, args, decompress)
+	case "pretty", "p":
+		err = convert(cmd, args, pretty)
+	case "compress", "c":
+		err = convert(cmd, args, mozlz4.Compress)
+	case "verify", "v":
+		err = verifyFiles(args)
+	default:
+		fmt.Fprintf(os.Stderr, "mozlz4: unknown command %q\n\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mozlz4: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: mozlz4 <command> [arguments]
+
+Commands:
+  decompress [-o output] file   decompress a mozLz4 file
+  pretty [-o output] file       decompress a mozLz4 file and indent its JSON
+  compress [-o output] file     compress a file to mozLz4
+  verify file...                check files decompress to valid JSON and
+                                survive a compression round-trip
+
+Output defaults to stdout, -o can also follow the file. Use - as file to
+read from stdin.
+`)
+}
+
+// convert reads the input file of a command, transforms it and writes the
+// result to the output.
+func convert(cmd string, args []string, transform func([]byte) ([]byte, error)) error {
+	flags := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	output := flags.String("o", "-", "output file")
+
+	// Flags may come before or after the file
+	var files []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		files = append(files, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(files) != 1 {
+		return errors.Errorf("%s expects a single file", cmd)
+	}
+
+	input, err := readInput(files[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := transform(input)
+	if err != nil {
+		return errors.Wrap(err, files[0])
+	}
+
+	if *output == "-" {
+		_, err = os.Stdout.Write(result)
+		return err
+	}
+	return os.WriteFile(*output, result, 0644)
+}
+
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func decompress(data []byte) ([]byte, error) {
+	return mozlz4.Decompress(data)
+}
+
+func pretty(data []byte) ([]byte, error) {
+	raw, err := mozlz4.Decompress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, bytes.TrimSpace(raw), "", "  "); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON content")
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// verifyFiles checks each file and reports the result on stdout.
+func verifyFiles(files []string) error {
+	if len(files) == 0 {
+		return errors.New("verify expects at least one file")
+	}
+
+	failed := 0
+	for _, file := range files {
+		size, err := verify(file)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", file, err)
+			failed++
+			continue
+		}
+		fmt.Printf("OK   %s (%d bytes)\n", file, size)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d file(s) failed verification", failed, len(files))
+	}
+	return nil
+}
+
+func verify(file string) (int, error) {
+	data, err := readInput(file)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := mozlz4.Decompress(data)
+	if err != nil {
+		return 0, err
+	}
+	if !json.Valid(raw) {
+		return 0, errors.New("decompressed content is not valid JSON")
+	}
+
+	compressed, err := mozlz4.Compress(raw)
+	if err != nil {
+		return 0, errors.Wrap(err, "round-trip compression failed")
+	}
+	roundTrip, err := mozlz4.Decompress(compressed)
+	if err != nil {
+		return 0, errors.Wrap(err, "round-trip decompression failed")
+	}
+	if !bytes.Equal(raw, roundTrip) {
+		return 0, errors.New("round-trip content differs")
+	}
+
+	return len(raw), nil
+}
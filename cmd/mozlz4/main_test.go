@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/mozlz4"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sessionstore.json")
+	compressed := filepath.Join(dir, "sessionstore.jsonlz4")
+	decompressed := filepath.Join(dir, "decompressed.json")
+	indented := filepath.Join(dir, "pretty.json")
+	data := []byte(`{"version":["sessionrestore",1],"windows":[]}`)
+	if err := os.WriteFile(input, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// -o after the file, then before it
+	if err := convert("compress", []string{input, "-o", compressed}, mozlz4.Compress); err != nil {
+		t.Fatal(err)
+	}
+	if err := convert("decompress", []string{"-o", decompressed, compressed}, decompress); err != nil {
+		t.Fatal(err)
+	}
+	if err := convert("pretty", []string{compressed, "-o", indented}, pretty); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := os.ReadFile(decompressed); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("decompressed = %q, %v, want %q", got, err, data)
+	}
+	want := "{\n  \"version\": [\n    \"sessionrestore\",\n    1\n  ],\n  \"windows\": []\n}\n"
+	if got, err := os.ReadFile(indented); err != nil || string(got) != want {
+		t.Errorf("pretty = %q, %v, want %q", got, err, want)
+	}
+
+	if err := verifyFiles([]string{compressed}); err != nil {
+		t.Errorf("verifyFiles(%s) = %v", compressed, err)
+	}
+	if err := verifyFiles([]string{compressed, input}); err == nil {
+		t.Errorf("verifyFiles(%s) succeeded on uncompressed JSON", input)
+	}
+}
+
+func TestConvertArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no file", args: []string{"-o", "out.json"}},
+		{name: "two files", args: []string{"a.jsonlz4", "b.jsonlz4"}},
+		{name: "two files around flag", args: []string{"a.jsonlz4", "-o", "out.json", "b.jsonlz4"}},
+		{name: "unknown flag", args: []string{"a.jsonlz4", "-x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := convert("decompress", tt.args, decompress); err == nil {
+				t.Errorf("convert(%q) succeeded", tt.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+	"github.com/portapps/portapps/v3/pkg/win"
+)
+
+// maxRunRecords is the number of Floorp runs kept in the run history.
+const maxRunRecords = 20
+
+// crashConfig holds the crash detection settings. With CollectDumps, the
+// crash reporter stays enabled to write minidumps under the crashreporter
+// folder, without ever submitting them. SafeModeAfter is the number of
+// consecutive crashes after which a restart in safe mode is offered, 0
+// disables the prompt. KeepDumps is the number of minidumps kept, 0 keeps
+// them all.
+type crashConfig struct {
+	CollectDumps  bool `yaml:"collect_dumps" mapstructure:"collect_dumps"`
+	SafeModeAfter int  `yaml:"safe_mode_after" mapstructure:"safe_mode_after"`
+	KeepDumps     int  `yaml:"keep_dumps" mapstructure:"keep_dumps"`
+}
+
+// runRecord describes a Floorp run.
+type runRecord struct {
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	ExitCode int       `json:"exit_code"`
+	SafeMode bool      `json:"safe_mode,omitempty"`
+	Dumps    []string  `json:"dumps,omitempty"`
+}
+
+// crashed reports whether the run ended with a crash.
+func (r runRecord) crashed() bool {
+	return r.ExitCode != 0 || len(r.Dumps) > 0
+}
+
+// crashreporterFolder returns the folder the crash reporter writes to.
+func crashreporterFolder() string {
+	return utl.PathJoin(app.DataPath, "crashreporter")
+}
+
+// superviseFloorp runs Floorp with the env environment and records its exit
+// code, duration and the minidumps written during the run.
+func superviseFloorp(args []string, env []string) (runRecord, error) {
+	dumpsBefore := minidumps()
+
+	record := runRecord{Start: time.Now()}
+	exitCode, err := runFloorp(args, env)
+	if err != nil {
+		return record, err
+	}
+	record.Duration = time.Since(record.Start).Seconds()
+	record.ExitCode = exitCode
+
+	for dump := range minidumps() {
+		if _, ok := dumpsBefore[dump]; !ok {
+			record.Dumps = append(record.Dumps, dump)
+		}
+	}
+
+	return record, nil
+}
+
+// minidumps returns the minidump files under the crashreporter folder.
+func minidumps() map[string]bool {
+	dumps := map[string]bool{}
+	_ = filepath.WalkDir(crashreporterFolder(), func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && strings.EqualFold(filepath.Ext(path), ".dmp") {
+			dumps[path] = true
+		}
+		return nil
+	})
+	return dumps
+}
+
+// recordRun appends a run to the history and returns the number of
+// consecutive crashes it ends with.
+func recordRun(record runRecord) int {
+	historyFile := utl.PathJoin(app.DataPath, "runs.json")
+
+	var history []runRecord
+	if raw, err := os.ReadFile(historyFile); err == nil {
+		if err := json.Unmarshal(raw, &history); err != nil {
+			log.Warn().Err(err).Msgf("Cannot read %s, starting a new history", historyFile)
+			history = nil
+		}
+	}
+
+	history = append(history, record)
+	if len(history) > maxRunRecords {
+		history = history[len(history)-maxRunRecords:]
+	}
+
+	raw, err := json.MarshalIndent(history, "", "  ")
+	if err == nil {
+		err = os.WriteFile(historyFile, raw, 0644)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Cannot write %s", historyFile)
+	}
+
+	crashes := 0
+	for i := len(history) - 1; i >= 0 && history[i].crashed(); i-- {
+		crashes++
+	}
+	return crashes
+}
+
+// logRun logs the outcome of a run, with a summary if Floorp crashed.
+func logRun(record runRecord, crashes int) {
+	duration := time.Duration(record.Duration * float64(time.Second)).Round(time.Second)
+	if !record.crashed() {
+		log.Info().Msgf("Floorp exited normally after %s", duration)
+		return
+	}
+
+	log.Error().Msgf("Floorp crashed after %s with exit code %s (%d consecutive crash(es))",
+		duration, formatExitCode(record.ExitCode), crashes)
+	if record.SafeMode {
+		log.Error().Msg("Floorp was running in safe mode")
+	}
+}
+
+// formatExitCode formats an exit code, Windows exception codes (e.g.
+// 0xC0000005 for an access violation) being shown in hexadecimal.
+func formatExitCode(exitCode int) string {
+	if uint32(exitCode) >= 0xC0000000 {
+		return fmt.Sprintf("%d (0x%08X)", exitCode, uint32(exitCode))
+	}
+	return fmt.Sprintf("%d", exitCode)
+}
+
+// confirmSafeMode asks the user if they want to restart Floorp in safe mode
+// after repeated crashes.
+func confirmSafeMode(crashes int) bool {
+	message := fmt.Sprintf(
+		"Floorp crashed %d times in a row.\n\n"+
+			"Do you want to restart it in safe mode, with extensions and themes disabled?",
+		crashes)
+
+	result, err := win.MsgBox(
+		fmt.Sprintf("%s crash", app.Name),
+		message,
+		win.MsgBoxBtnYesNo|win.MsgBoxIconQuestion)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot create dialog box")
+		return false
+	}
+
+	return result == 6 // IDYES
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordRun(t *testing.T) {
+	saved := *app
+	t.Cleanup(func() {
+		*app = saved
+	})
+	app.DataPath = t.TempDir()
+
+	clean := runRecord{ExitCode: 0}
+	exitCode := runRecord{ExitCode: 1}
+	dump := runRecord{Dumps: []string{"a.dmp"}}
+	tests := []struct {
+		record runRecord
+		want   int
+	}{
+		{record: exitCode, want: 1},
+		{record: dump, want: 2},
+		{record: clean, want: 0},
+		{record: exitCode, want: 1},
+		{record: exitCode, want: 2},
+		{record: exitCode, want: 3},
+	}
+	for i, tt := range tests {
+		if got := recordRun(tt.record); got != tt.want {
+			t.Errorf("run %d: recordRun() = %d, want %d", i, got, tt.want)
+		}
+	}
+
+	// Only the last runs are kept, so crashes are counted within them
+	for i := 0; i < maxRunRecords+5; i++ {
+		recordRun(exitCode)
+	}
+	if got := recordRun(exitCode); got != maxRunRecords {
+		t.Errorf("recordRun() = %d after a long crash series, want %d", got, maxRunRecords)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(app.DataPath, "runs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var history []runRecord
+	if err := json.Unmarshal(raw, &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != maxRunRecords {
+		t.Errorf("history has %d runs, want %d", len(history), maxRunRecords)
+	}
+}
+
+func TestRecordRunCorruptHistory(t *testing.T) {
+	saved := *app
+	t.Cleanup(func() {
+		*app = saved
+	})
+	app.DataPath = t.TempDir()
+	writeFiles(t, map[string]string{filepath.Join(app.DataPath, "runs.json"): "{not json"})
+
+	if got := recordRun(runRecord{ExitCode: 1}); got != 1 {
+		t.Errorf("recordRun() = %d, want 1", got)
+	}
+}
+
+func TestFormatExitCode(t *testing.T) {
+	tests := []struct {
+		exitCode int
+		want     string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{-1, "-1 (0xFFFFFFFF)"},
+		{int(int32(-1073741819)), "-1073741819 (0xC0000005)"},
+		{0xC0000005, "3221225477 (0xC0000005)"},
+		{int(int32(-1073741571)), "-1073741571 (0xC00000FD)"},
+		{0xBFFFFFFF, "3221225471"},
+	}
+	for _, tt := range tests {
+		if got := formatExitCode(tt.exitCode); got != tt.want {
+			t.Errorf("formatExitCode(%d) = %q, want %q", tt.exitCode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// crashDump is a minidump of the crashreporter folder with the annotations
+// of its .extra file, if any.
+type crashDump struct {
+	Path    string
+	Extra   string
+	Time    time.Time
+	Size    int64
+	Product string
+	Version string
+	BuildID string
+	Reason  string
+	Process string
+}
+
+// crashExtra holds the .extra annotations used in crash summaries.
+type crashExtra struct {
+	ProductName    string `json:"ProductName"`
+	Version        string `json:"Version"`
+	BuildID        string `json:"BuildID"`
+	CrashTime      string `json:"CrashTime"`
+	MozCrashReason string `json:"MozCrashReason"`
+	ProcessType    string `json:"ProcessType"`
+	IPCError       string `json:"ipc_channel_error"`
+}
+
+// String returns a one-line summary of the crash.
+func (d crashDump) String() string {
+	version := d.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	reason := d.Reason
+	if reason == "" {
+		reason = "unknown reason"
+	}
+	return fmt.Sprintf("%s %s %s, %s (%s)", d.Time.Format("2006-01-02 15:04:05"), d.Product, version, reason, filepath.Base(d.Path))
+}
+
+// listCrashDumps returns the minidumps of the crashreporter folder, newest
+// first.
+func listCrashDumps() ([]crashDump, error) {
+	var dumps []crashDump
+	err := filepath.WalkDir(crashreporterFolder(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".dmp") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		dumps = append(dumps, readCrashDump(path, info))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot list crash dumps")
+	}
+
+	sort.Slice(dumps, func(i, j int) bool {
+		return dumps[i].Time.After(dumps[j].Time)
+	})
+	return dumps, nil
+}
+
+// readCrashDump describes a minidump from its .extra file. Missing or invalid
+// annotations leave the fields empty, and the file time is used instead of the
+// crash time.
+func readCrashDump(path string, info fs.FileInfo) crashDump {
+	dump := crashDump{
+		Path:    path,
+		Time:    info.ModTime(),
+		Size:    info.Size(),
+		Product: app.Name,
+	}
+
+	extraPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".extra"
+	raw, err := os.ReadFile(extraPath)
+	if err != nil {
+		return dump
+	}
+	dump.Extra = extraPath
+
+	var extra crashExtra
+	if err := json.Unmarshal(raw, &extra); err != nil {
+		log.Warn().Err(err).Msgf("Cannot parse %s", extraPath)
+		return dump
+	}
+	if extra.ProductName != "" {
+		dump.Product = extra.ProductName
+	}
+	dump.Version = extra.Version
+	dump.BuildID = extra.BuildID
+	dump.Process = extra.ProcessType
+	dump.Reason = extra.MozCrashReason
+	if dump.Reason == "" {
+		dump.Reason = extra.IPCError
+	}
+	if seconds, err := strconv.ParseInt(extra.CrashTime, 10, 64); err == nil {
+		dump.Time = time.Unix(seconds, 0)
+	}
+
+	return dump
+}
+
+// rotateCrashDumps removes the oldest minidumps and their .extra files,
+// keeping the newest keep dumps. 0 keeps every dump.
+func rotateCrashDumps(keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	dumps, err := listCrashDumps()
+	if err != nil {
+		return err
+	}
+	if len(dumps) <= keep {
+		return nil
+	}
+
+	for _, dump := range dumps[keep:] {
+		log.Info().Msgf("Removing old crash dump %s", dump.Path)
+		for _, file := range []string{dump.Path, dump.Extra} {
+			if file == "" {
+				continue
+			}
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				log.Error().Err(err).Msgf("Cannot remove %s", file)
+			}
+		}
+	}
+	return nil
+}
+
+// inventoryCrashDumps logs the minidumps written during a run and rotates the
+// old ones.
+func inventoryCrashDumps(record runRecord) {
+	for _, path := range record.Dumps {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		log.Error().Msgf("Crash: %s", readCrashDump(path, info))
+	}
+
+	if err := rotateCrashDumps(cfg.Crash.KeepDumps); err != nil {
+		log.Error().Err(err).Msg("Cannot rotate crash dumps")
+	}
+}
+
+// writeCrashReport bundles the crash dumps, the run history and the launcher
+// logs into a zip file for support. The file defaults to
+// crash-report-<date>.zip in the portable folder. Text files are redacted, and
+// the file is removed if the report cannot be completed.
+func writeCrashReport(output string) (string, error) {
+	if output == "" {
+		output = utl.PathJoin(app.RootPath, fmt.Sprintf("crash-report-%s.zip", time.Now().Format("20060102-150405")))
+	}
+
+	dumps, err := listCrashDumps()
+	if err != nil {
+		return "", err
+	}
+
+	err = writeReportFile(output, "crash report", func(w io.Writer) error {
+		return writeCrashArchive(w, dumps)
+	})
+	if err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// writeReportFile creates output and fills it with write. The file is removed
+// if it cannot be fully written, so no truncated report is left behind.
+func writeReportFile(output string, description string, write func(w io.Writer) error) error {
+	file, err := os.Create(output)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot create %s", description)
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = errors.Wrapf(closeErr, "Cannot write %s", description)
+	}
+	if err != nil {
+		if removeErr := os.Remove(output); removeErr != nil {
+			log.Error().Err(removeErr).Msgf("Cannot remove %s", output)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeCrashArchive writes the crash report archive to w.
+func writeCrashArchive(w io.Writer, dumps []crashDump) error {
+	archive := zip.NewWriter(w)
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s crash report, %s\n\n", app.Name, time.Now().Format(time.RFC3339))
+	if len(dumps) == 0 {
+		summary.WriteString("No crash dump found.\n")
+	}
+	for _, dump := range dumps {
+		fmt.Fprintf(&summary, "%s\n", dump)
+		if dump.BuildID != "" {
+			fmt.Fprintf(&summary, "  build id: %s\n", dump.BuildID)
+		}
+		if dump.Process != "" {
+			fmt.Fprintf(&summary, "  process: %s\n", dump.Process)
+		}
+		fmt.Fprintf(&summary, "  size: %d bytes\n", dump.Size)
+
+		if err := addFileToZip(archive, crashArchiveName(dump.Path), dump.Path); err != nil {
+			return err
+		}
+		if dump.Extra != "" {
+			raw, err := os.ReadFile(dump.Extra)
+			if err != nil {
+				return errors.Wrapf(err, "Cannot read %s", dump.Extra)
+			}
+			if err := addBytesToZip(archive, crashArchiveName(dump.Extra), redactCrashExtra(raw)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := addBytesToZip(archive, "summary.txt", []byte(summary.String())); err != nil {
+		return err
+	}
+	textFiles := map[string]string{}
+	if runs := utl.PathJoin(app.DataPath, "runs.json"); utl.Exists(runs) {
+		textFiles["runs.json"] = runs
+	}
+	logs, _ := filepath.Glob(utl.PathJoin(app.RootPath, "log", "*.log"))
+	for _, logFile := range logs {
+		textFiles["log/"+filepath.Base(logFile)] = logFile
+	}
+	for name, path := range textFiles {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot read %s", path)
+		}
+		if err := addBytesToZip(archive, name, []byte(redactText(string(raw)))); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return errors.Wrap(err, "Cannot write crash report")
+	}
+	return nil
+}
+
+// crashExtraPrivateKeys are the .extra annotations left out of crash reports:
+// the page being browsed and what the user typed in the crash reporter.
+var crashExtraPrivateKeys = []string{"URL", "Email", "Comments"}
+
+// redactCrashExtra removes the private annotations of a .extra file and masks
+// credentials in the others. A file that is not valid JSON is only masked.
+func redactCrashExtra(raw []byte) []byte {
+	var extra map[string]interface{}
+	if err := json.Unmarshal(raw, &extra); err == nil {
+		for _, key := range crashExtraPrivateKeys {
+			delete(extra, key)
+		}
+		if redacted, err := json.Marshal(extra); err == nil {
+			raw = redacted
+		}
+	}
+	return []byte(redactText(string(raw)))
+}
+
+// crashArchiveName returns the name of a crash file in the report. Dumps of
+// the pending and submitted folders can share a name, so their folder relative
+// to the crashreporter folder is kept.
+func crashArchiveName(path string) string {
+	rel, err := filepath.Rel(crashreporterFolder(), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Join(filepath.Base(filepath.Dir(path)), filepath.Base(path))
+	}
+	return "crashes/" + filepath.ToSlash(rel)
+}
+
+// addFileToZip copies a file into the archive under name.
+func addFileToZip(archive *zip.Writer, name string, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot open %s", path)
+	}
+	defer in.Close()
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if info, err := in.Stat(); err == nil {
+		header.Modified = info.ModTime()
+	}
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot add %s", name)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return errors.Wrapf(err, "Cannot add %s", name)
+	}
+	return nil
+}
+
+// addBytesToZip writes data into the archive under name.
+func addBytesToZip(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return errors.Wrapf(err, "Cannot add %s", name)
+	}
+	_, err = w.Write(data)
+	return errors.Wrapf(err, "Cannot add %s", name)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/utl"
+	"golang.org/x/sys/windows"
+)
+
+// diagnosticCheck is the result of a self-check of the diagnostics.
+type diagnosticCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// redactKey matches the part of a key suggesting a credential.
+const redactKey = `[\w.-]*(?:token|password|passwd|secret|cookie|authorization|credential|api[_-]?key)[\w.-]*`
+
+var (
+	// redactJSONString matches JSON string members whose key suggests a
+	// credential, e.g. "token": "abc". Objects, arrays, numbers and booleans
+	// are kept, so the JSON stays valid and settings like "Cookies": {...}
+	// stay readable.
+	redactJSONString = regexp.MustCompile(`(?i)("` + redactKey + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// redactKeyValue matches YAML, INI and log values whose key suggests a
+	// credential, e.g. password: abc def. Unquoted values run to the end of
+	// the line, or to the closing quote of a JSON log line.
+	redactKeyValue = regexp.MustCompile(`(?im)(^|[^\w.?&-])(` + redactKey + `[ \t]*[:=][ \t]*)("(?:[^"\\]|\\.)*"|'[^']*'|(?:[^\r\n"\\]|\\.)+)`)
+	// redactKeepValue matches unquoted values that are not credentials: YAML
+	// booleans and nulls, flow maps and lists, and the start of a map, list
+	// or block scalar.
+	redactKeepValue = regexp.MustCompile(`(?i)^(?:true|false|yes|no|on|off|null|~|\{.*\}|\[.*\]|[{\[]|[|>][-+]?\d*)$`)
+	// redactURLUserinfo matches the credentials of a URL.
+	redactURLUserinfo = regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.-]*://)[^/\s:@"']+:[^/\s@"']+@`)
+	// redactURLQuery matches URL query parameters holding a credential.
+	redactURLQuery = regexp.MustCompile(`(?i)([?&](?:token|access_token|auth|key|sig|signature|password)=)[^&\s"'#]+`)
+)
+
+// redactText masks tokens, passwords and cookies in configuration and log
+// files before they are added to a diagnostics report.
+func redactText(content string) string {
+	content = redactJSONString.ReplaceAllString(content, `${1}"********"`)
+	content = redactKeyValue.ReplaceAllStringFunc(content, func(match string) string {
+		parts := redactKeyValue.FindStringSubmatch(match)
+		value := strings.TrimSpace(parts[3])
+		switch {
+		case strings.HasPrefix(value, `"`):
+			return parts[1] + parts[2] + `"********"`
+		case strings.HasPrefix(value, `'`):
+			return parts[1] + parts[2] + `'********'`
+		case value == "" || redactKeepValue.MatchString(value):
+			return match
+		}
+		return parts[1] + parts[2] + "********"
+	})
+	content = redactURLUserinfo.ReplaceAllString(content, "${1}********@")
+	return redactURLQuery.ReplaceAllString(content, "${1}********")
+}
+
+// writeDiagnostics runs the self-checks and writes a report archive with the
+// launcher logs, configuration, effective policies, generated files, version
+// info and a listing of the profile files. The archive defaults to
+// diagnose-<date>.zip in the portable folder. A summary is written to w.
+func writeDiagnostics(w io.Writer, profileFolder string, output string) (string, error) {
+	if output == "" {
+		output = utl.PathJoin(app.RootPath, fmt.Sprintf("diagnose-%s.zip", time.Now().Format("20060102-150405")))
+	}
+
+	var summary string
+	err := writeReportFile(output, "diagnostics report", func(w io.Writer) (err error) {
+		summary, err = writeDiagnosticsArchive(w, profileFolder)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprint(w, summary)
+	return output, nil
+}
+
+// writeDiagnosticsArchive writes the diagnostics report archive to w and
+// returns its summary.
+func writeDiagnosticsArchive(w io.Writer, profileFolder string) (string, error) {
+	archive := zip.NewWriter(w)
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s diagnostics, %s\n\n", app.Name, time.Now().Format(time.RFC3339))
+	tw := tabwriter.NewWriter(&summary, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Launcher\t%s-%s (portapps %s)\n", app.Info.Version, app.Info.Release, app.Info.PortappsVersion)
+	fmt.Fprintf(tw, "Floorp\t%s (build %s)\n", valueOrUnknown(applicationInfo("Version")), valueOrUnknown(applicationInfo("BuildID")))
+	fmt.Fprintf(tw, "Windows\t%d.%d.%d\n", app.WinVersion.Major, app.WinVersion.Minor, app.WinVersion.Build)
+	fmt.Fprintf(tw, "Root path\t%s\n", app.RootPath)
+	fmt.Fprintf(tw, "App path\t%s\n", app.AppPath)
+	fmt.Fprintf(tw, "Profile\t%s\n", profileFolder)
+	tw.Flush()
+
+	summary.WriteString("\nSelf-checks:\n")
+	for _, check := range diagnosticChecks(profileFolder) {
+		status := "OK"
+		if !check.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&summary, "  [%s] %s: %s\n", status, check.Name, check.Detail)
+	}
+
+	// Text files are redacted, the profile is only listed
+	textFiles := map[string]string{
+		"config/" + app.ID + ".yml":      utl.PathJoin(app.RootPath, app.ID+".yml"),
+		"config/portapp.json":            utl.PathJoin(app.RootPath, "portapp.json"),
+		"config/portapp-prev.json":       utl.PathJoin(app.RootPath, "portapp-prev.json"),
+		"app/portapps.cfg":               utl.PathJoin(app.AppPath, "portapps.cfg"),
+		"app/application.ini":            utl.PathJoin(app.AppPath, "application.ini"),
+		"app/distribution/policies.json": utl.PathJoin(app.AppPath, "distribution", "policies.json"),
+		"data/runs.json":                 utl.PathJoin(app.DataPath, "runs.json"),
+	}
+	logs, _ := filepath.Glob(utl.PathJoin(app.RootPath, "log", "*.log"))
+	for _, logFile := range logs {
+		textFiles["log/"+filepath.Base(logFile)] = logFile
+	}
+	for name, path := range textFiles {
+		raw, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			fmt.Fprintf(&summary, "Cannot read %s: %v\n", path, err)
+			continue
+		}
+		if err := addBytesToZip(archive, name, []byte(redactText(string(raw)))); err != nil {
+			return "", err
+		}
+	}
+
+	if set, _, err := effectivePolicies(); err != nil {
+		fmt.Fprintf(&summary, "Cannot load effective policies: %v\n", err)
+	} else if policies, err := set.JSON(); err == nil {
+		if err := addBytesToZip(archive, "policies-effective.json", []byte(redactText(policies.StringIndent("", "  ")))); err != nil {
+			return "", err
+		}
+	}
+
+	listing, err := profileListing(profileFolder)
+	if err != nil {
+		fmt.Fprintf(&summary, "Cannot list profile files: %v\n", err)
+	}
+	if err := addBytesToZip(archive, "profile-files.txt", []byte(listing)); err != nil {
+		return "", err
+	}
+
+	if err := addBytesToZip(archive, "summary.txt", []byte(summary.String())); err != nil {
+		return "", err
+	}
+	if err := archive.Close(); err != nil {
+		return "", errors.Wrap(err, "Cannot write diagnostics report")
+	}
+	return summary.String(), nil
+}
+
+// diagnosticChecks checks the installation, the profile and the running
+// instance.
+func diagnosticChecks(profileFolder string) []diagnosticCheck {
+	var checks []diagnosticCheck
+
+	appCheck := diagnosticCheck{Name: "App folder", OK: utl.Exists(app.AppPath), Detail: app.AppPath}
+	if !appCheck.OK {
+		appCheck.Detail += " not found"
+	}
+	checks = append(checks, appCheck)
+
+	process := utl.PathJoin(app.AppPath, "floorp.exe")
+	processCheck := diagnosticCheck{Name: "Floorp executable", OK: utl.Exists(process), Detail: process}
+	if !processCheck.OK {
+		processCheck.Detail += " not found"
+	}
+	checks = append(checks, processCheck)
+
+	writableCheck := diagnosticCheck{Name: "Profile writable", OK: true, Detail: profileFolder}
+	probe := filepath.Join(profileFolder, fmt.Sprintf(".diagnose-%d", os.Getpid()))
+	if err := os.WriteFile(probe, []byte{}, 0644); err != nil {
+		writableCheck.OK = false
+		writableCheck.Detail = err.Error()
+	} else {
+		_ = os.Remove(probe)
+	}
+	checks = append(checks, writableCheck)
+
+	relocations := relocationPaths()
+	checks = append(checks, runningInstanceCheck(instanceRunning(), len(relocations) > 0))
+
+	relocationCheck := diagnosticCheck{Name: "Relocation", OK: true, Detail: "not needed"}
+	if len(relocations) > 0 {
+		moves := make([]string, 0, len(relocations))
+		for _, relocation := range relocations {
+			moves = append(moves, fmt.Sprintf("%s -> %s", relocation.Prev, relocation.Curr))
+		}
+		relocationCheck.Detail = "needed on next launch, " + strings.Join(moves, ", ")
+	}
+	checks = append(checks, relocationCheck)
+
+	return checks
+}
+
+// runningInstanceCheck reports another launcher instance. It only fails when
+// a relocation is pending, as the next launch would then rewrite the paths of
+// a profile the other instance may be using.
+func runningInstanceCheck(running bool, relocationPending bool) diagnosticCheck {
+	check := diagnosticCheck{Name: "Running instance", OK: true, Detail: "none"}
+	if running {
+		check.Detail = "another launcher instance holds the mutex"
+		if relocationPending {
+			check.OK = false
+			check.Detail += " while a relocation is pending"
+		}
+	}
+	return check
+}
+
+// instanceRunning reports whether a launcher instance holds the mutex, without
+// creating it.
+func instanceRunning() bool {
+	name, err := windows.UTF16PtrFromString("Portapps" + app.ID)
+	if err != nil {
+		return false
+	}
+	handle, err := windows.OpenMutex(windows.SYNCHRONIZE, false, name)
+	if err != nil {
+		return false
+	}
+	windows.CloseHandle(handle)
+	return true
+}
+
+// profileListing lists the files of the profile with their size and
+// modification time, without their content.
+func profileListing(profileFolder string) (string, error) {
+	var listing strings.Builder
+	tw := tabwriter.NewWriter(&listing, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIZE\tMODIFIED\tPATH")
+	err := filepath.WalkDir(profileFolder, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintf(tw, "-\t-\t%s (%v)\n", path, err)
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(profileFolder, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			fmt.Fprintf(tw, "-\t-\t%s (%v)\n", rel, err)
+			return nil
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", info.Size(), info.ModTime().Format("2006-01-02 15:04:05"), rel)
+		return nil
+	})
+	tw.Flush()
+	return listing.String(), err
+}
+
+// valueOrUnknown returns value, or "unknown" if it is empty.
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/portapps/portapps/v3/pkg/log"
+)
+
+// expandEnvValue expands ${DATA}, ${APP}, ${PROFILE} and ${ROOT} in an
+// environment value, other variables are taken from the launcher environment.
+func expandEnvValue(value string, profileFolder string) string {
+	return os.Expand(value, func(name string) string {
+		switch name {
+		case "DATA":
+			return app.DataPath
+		case "APP":
+			return app.AppPath
+		case "PROFILE":
+			return profileFolder
+		case "ROOT":
+			return app.RootPath
+		}
+		return os.Getenv(name)
+	})
+}
+
+// childEnv returns the environment of the browser process: the launcher
+// environment with the env and env_unset configuration applied. The launcher
+// environment itself is never changed.
+func childEnv(profileFolder string) []string {
+	environ := os.Environ()
+	if len(cfg.Env) == 0 && len(cfg.EnvUnset) == 0 {
+		return environ
+	}
+
+	names := make([]string, 0, len(cfg.Env))
+	for name := range cfg.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Expand every value first, so values refer to the inherited environment
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = expandEnvValue(cfg.Env[name], profileFolder)
+	}
+
+	// Variable names are case-insensitive on Windows
+	removed := map[string]bool{}
+	for _, name := range cfg.EnvUnset {
+		log.Info().Msgf("Unsetting environment variable %s", name)
+		removed[strings.ToUpper(name)] = true
+	}
+	for _, name := range names {
+		removed[strings.ToUpper(name)] = true
+	}
+
+	env := make([]string, 0, len(environ)+len(names))
+	for _, entry := range environ {
+		if name, _, _ := strings.Cut(entry, "="); removed[strings.ToUpper(name)] {
+			continue
+		}
+		env = append(env, entry)
+	}
+	for i, name := range names {
+		log.Info().Msgf("Setting environment variable %s=%s", name, redactEnvValue(name, values[i]))
+		env = append(env, name+"="+values[i])
+	}
+
+	return env
+}
+
+// redactEnvValue hides the value of variables that may hold credentials,
+// e.g. proxy URLs with a password.
+func redactEnvValue(name string, value string) string {
+	name = strings.ToUpper(name)
+	for _, sensitive := range []string{"PROXY", "PASSWORD", "SECRET", "TOKEN", "KEY"} {
+		if strings.Contains(name, sensitive) {
+			return "********"
+		}
+	}
+	return value
+}
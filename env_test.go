@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChildEnv(t *testing.T) {
+	env, envUnset, dataPath := cfg.Env, cfg.EnvUnset, app.DataPath
+	t.Cleanup(func() {
+		cfg.Env, cfg.EnvUnset, app.DataPath = env, envUnset, dataPath
+	})
+
+	t.Setenv("FLOORP_TEST_KEEP", "kept")
+	t.Setenv("FLOORP_TEST_UNSET", "removed")
+	t.Setenv("FLOORP_TEST_OVERRIDE", "inherited")
+	app.DataPath = `E:\Floorp\data`
+	cfg.Env = map[string]string{
+		"FLOORP_TEST_OVERRIDE": "${FLOORP_TEST_OVERRIDE}-child",
+		"FLOORP_TEST_LOG":      `${DATA}\logs\moz.log`,
+		"FLOORP_TEST_PROFILE":  "${PROFILE}",
+	}
+	cfg.EnvUnset = []string{"floorp_test_unset"}
+
+	got := map[string]string{}
+	for _, entry := range childEnv(`E:\Floorp\data\profile`) {
+		name, value, _ := strings.Cut(entry, "=")
+		if _, ok := got[name]; ok {
+			t.Errorf("%s is set twice", name)
+		}
+		got[name] = value
+	}
+
+	want := map[string]string{
+		"FLOORP_TEST_KEEP":     "kept",
+		"FLOORP_TEST_OVERRIDE": "inherited-child",
+		"FLOORP_TEST_LOG":      `E:\Floorp\data\logs\moz.log`,
+		"FLOORP_TEST_PROFILE":  `E:\Floorp\data\profile`,
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+	if value, ok := got["FLOORP_TEST_UNSET"]; ok {
+		t.Errorf("FLOORP_TEST_UNSET = %q, want unset", value)
+	}
+
+	// The launcher environment is left untouched
+	if value := os.Getenv("FLOORP_TEST_OVERRIDE"); value != "inherited" {
+		t.Errorf("launcher FLOORP_TEST_OVERRIDE = %q, want %q", value, "inherited")
+	}
+	if _, ok := os.LookupEnv("FLOORP_TEST_UNSET"); !ok {
+		t.Error("launcher FLOORP_TEST_UNSET was unset")
+	}
+	if _, ok := os.LookupEnv("FLOORP_TEST_LOG"); ok {
+		t.Error("launcher FLOORP_TEST_LOG was set")
+	}
+}
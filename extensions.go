@@ -0,0 +1,331 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// Locations an extension can be installed to.
+const (
+	extensionLocationProfile      = "profile"
+	extensionLocationDistribution = "distribution"
+)
+
+// extensionConfig is an extension provisioned by the launcher. Either Path
+// (relative to the data folder) or URL must be set.
+type extensionConfig struct {
+	ID       string `yaml:"id" mapstructure:"id"`
+	Path     string `yaml:"path" mapstructure:"path"`
+	URL      string `yaml:"url" mapstructure:"url"`
+	Location string `yaml:"location" mapstructure:"location"`
+}
+
+// installedExtension is the state of an extension installed by the launcher.
+type installedExtension struct {
+	Version  string `json:"version"`
+	Location string `json:"location"`
+	Source   string `json:"source"`
+	Hash     string `json:"sha256"`
+}
+
+// extensionsState holds the extensions installed by the launcher, by profile
+// name and extension id.
+type extensionsState map[string]map[string]installedExtension
+
+// xpiManifest holds the fields read from the manifest.json of an XPI.
+type xpiManifest struct {
+	Version                string `json:"version"`
+	BrowserSpecificSetting struct {
+		Gecko struct {
+			ID string `json:"id"`
+		} `json:"gecko"`
+	} `json:"browser_specific_settings"`
+	Applications struct {
+		Gecko struct {
+			ID string `json:"id"`
+		} `json:"gecko"`
+	} `json:"applications"`
+}
+
+// provisionExtensions installs, upgrades and removes the extensions listed in
+// the configuration. Only extensions installed by the launcher in the current
+// profile are removed, including the copy Floorp made of a distribution one.
+func provisionExtensions(profileFolder string) error {
+	stateFile := utl.PathJoin(app.DataPath, "extensions.json")
+	states, err := readExtensionsState(stateFile)
+	if err != nil {
+		return err
+	}
+	state := states[cfg.Profile]
+	if len(cfg.Extensions) == 0 && len(state) == 0 {
+		return nil
+	}
+	if state == nil {
+		state = map[string]installedExtension{}
+	}
+
+	wanted := map[string]bool{}
+	for _, extension := range cfg.Extensions {
+		id, installed, err := provisionExtension(profileFolder, extension, state)
+		if err != nil {
+			log.Error().Err(err).Msgf("Cannot provision extension %s", extensionSource(extension))
+			// Keep the previous install of a failing extension
+			for id, installed := range state {
+				if id == extension.ID || installed.Source == extensionSource(extension) {
+					wanted[id] = true
+				}
+			}
+			continue
+		}
+		wanted[id] = true
+		state[id] = installed
+	}
+
+	for id, installed := range state {
+		if wanted[id] {
+			continue
+		}
+		log.Info().Msgf("Removing extension %s %s", id, installed.Version)
+		if err := removeExtension(profileFolder, id, installed.Location); err != nil {
+			log.Error().Err(err).Msgf("Cannot remove extension %s", id)
+			continue
+		}
+		delete(state, id)
+	}
+
+	if len(state) == 0 {
+		delete(states, cfg.Profile)
+	} else {
+		states[cfg.Profile] = state
+	}
+	return writeExtensionsState(stateFile, states)
+}
+
+// removeExtension uninstalls an extension from a profile. Floorp installs a
+// distribution extension by copying it to the profile, so this copy is removed
+// along with the shared one: each profile that installed it drops it on its
+// next launch.
+func removeExtension(profileFolder string, id string, location string) error {
+	xpiFiles := []string{filepath.Join(extensionsFolder(profileFolder, location), id+".xpi")}
+	if location == extensionLocationDistribution {
+		xpiFiles = append(xpiFiles, filepath.Join(extensionsFolder(profileFolder, extensionLocationProfile), id+".xpi"))
+	}
+	for _, xpiFile := range xpiFiles {
+		if err := os.Remove(xpiFile); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "Cannot remove %s", xpiFile)
+		}
+	}
+	return nil
+}
+
+// provisionExtension installs an extension if it is missing or its source has
+// changed, and returns its id and state.
+func provisionExtension(profileFolder string, extension extensionConfig, state map[string]installedExtension) (string, installedExtension, error) {
+	location := strings.ToLower(extension.Location)
+	switch location {
+	case "":
+		location = extensionLocationDistribution
+	case extensionLocationProfile, extensionLocationDistribution:
+	default:
+		return "", installedExtension{}, errors.Errorf("Unknown location %s", extension.Location)
+	}
+
+	source, err := extensionFile(extension)
+	if err != nil {
+		return "", installedExtension{}, err
+	}
+	manifest, err := readXpiManifest(source)
+	if err != nil {
+		return "", installedExtension{}, err
+	}
+	hash, err := fileSHA256(source)
+	if err != nil {
+		return "", installedExtension{}, err
+	}
+
+	id := extension.ID
+	if id == "" {
+		if id = manifest.BrowserSpecificSetting.Gecko.ID; id == "" {
+			id = manifest.Applications.Gecko.ID
+		}
+		if id == "" {
+			return "", installedExtension{}, errors.Errorf("No extension id found in %s, set id in config", source)
+		}
+	}
+
+	installed := installedExtension{
+		Version:  manifest.Version,
+		Location: location,
+		Source:   extensionSource(extension),
+		Hash:     hash,
+	}
+
+	xpiFile := filepath.Join(extensionsFolder(profileFolder, location), id+".xpi")
+	if previous, ok := state[id]; ok {
+		if previous == installed && utl.Exists(xpiFile) {
+			log.Debug().Msgf("Extension %s %s is up to date", id, installed.Version)
+			return id, installed, nil
+		}
+		if previous.Location != location {
+			previousFile := filepath.Join(extensionsFolder(profileFolder, previous.Location), id+".xpi")
+			if err := os.Remove(previousFile); err != nil && !os.IsNotExist(err) {
+				return "", installedExtension{}, errors.Wrapf(err, "Cannot remove %s", previousFile)
+			}
+		}
+		log.Info().Msgf("Upgrading extension %s from %s to %s", id, previous.Version, installed.Version)
+	} else {
+		log.Info().Msgf("Installing extension %s %s in %s", id, installed.Version, location)
+	}
+
+	utl.CreateFolder(filepath.Dir(xpiFile))
+	if err := copyFile(source, xpiFile); err != nil {
+		return "", installedExtension{}, errors.Wrapf(err, "Cannot copy %s", source)
+	}
+
+	// Floorp only installs distribution extensions in new profiles or after
+	// an update, which it detects through compatibility.ini
+	if location == extensionLocationDistribution {
+		compatibilityFile := filepath.Join(profileFolder, "compatibility.ini")
+		if err := os.Remove(compatibilityFile); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("Cannot remove %s", compatibilityFile)
+		}
+	}
+
+	return id, installed, nil
+}
+
+// extensionFile returns the local XPI file of an extension. Remote XPIs are
+// downloaded once to the extensions cache of the data folder, so they can
+// still be installed offline.
+func extensionFile(extension extensionConfig) (string, error) {
+	if extension.Path != "" {
+		source := extension.Path
+		if !filepath.IsAbs(source) {
+			source = utl.PathJoin(app.DataPath, source)
+		}
+		if !utl.Exists(source) {
+			return "", errors.Errorf("%s not found", source)
+		}
+		return source, nil
+	}
+	if extension.URL == "" {
+		return "", errors.New("Either path or url must be set")
+	}
+
+	urlHash := sha256.Sum256([]byte(extension.URL))
+	cached := utl.PathJoin(utl.CreateFolder(app.DataPath, "extensions-cache"), hex.EncodeToString(urlHash[:8])+".xpi")
+	if utl.Exists(cached) {
+		return cached, nil
+	}
+
+	if err := downloadFile(extension.URL, cached+".part"); err != nil {
+		os.Remove(cached + ".part")
+		return "", errors.Wrapf(err, "Cannot download %s", extension.URL)
+	}
+	if err := os.Rename(cached+".part", cached); err != nil {
+		return "", errors.Wrapf(err, "Cannot move %s to cache", extension.URL)
+	}
+
+	return cached, nil
+}
+
+// extensionsFolder returns the folder extensions of a location are installed to.
+func extensionsFolder(profileFolder string, location string) string {
+	if location == extensionLocationDistribution {
+		return utl.PathJoin(app.AppPath, "distribution", "extensions")
+	}
+	return utl.PathJoin(profileFolder, "extensions")
+}
+
+func extensionSource(extension extensionConfig) string {
+	if extension.URL != "" {
+		return extension.URL
+	}
+	return extension.Path
+}
+
+// readXpiManifest reads the manifest.json of an XPI.
+func readXpiManifest(filename string) (xpiManifest, error) {
+	var manifest xpiManifest
+
+	xpi, err := zip.OpenReader(filename)
+	if err != nil {
+		return manifest, errors.Wrapf(err, "Cannot open %s", filename)
+	}
+	defer xpi.Close()
+
+	file, err := xpi.Open("manifest.json")
+	if err != nil {
+		return manifest, errors.Wrapf(err, "No manifest.json found in %s", filename)
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&manifest); err != nil {
+		return manifest, errors.Wrapf(err, "Cannot parse manifest.json of %s", filename)
+	}
+
+	return manifest, nil
+}
+
+// readExtensionsState reads the extensions installed by the launcher. A state
+// written before it was kept by profile is attributed to the current profile.
+func readExtensionsState(filename string) (extensionsState, error) {
+	states := extensionsState{}
+	if !utl.Exists(filename) {
+		return states, nil
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read %s", filename)
+	}
+	if err := json.Unmarshal(raw, &states); err != nil {
+		var legacy map[string]installedExtension
+		if json.Unmarshal(raw, &legacy) != nil {
+			return nil, errors.Wrapf(err, "Cannot parse %s", filename)
+		}
+		states = extensionsState{cfg.Profile: legacy}
+	}
+
+	return states, nil
+}
+
+func writeExtensionsState(filename string, state extensionsState) error {
+	if len(state) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, raw, 0644)
+}
+
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeXpi writes an XPI whose manifest declares id and version.
+func writeXpi(t *testing.T, filename string, id string, version string) {
+	t.Helper()
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	xpi := zip.NewWriter(file)
+	manifest, err := xpi.Create("manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"version":                   version,
+		"browser_specific_settings": map[string]interface{}{"gecko": map[string]interface{}{"id": id}},
+	})
+	if _, err := manifest.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := xpi.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupExtensions points the app to a temporary portable folder and returns
+// the profile folder.
+func setupExtensions(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	saved, savedProfile, savedExtensions := *app, cfg.Profile, cfg.Extensions
+	t.Cleanup(func() {
+		*app, cfg.Profile, cfg.Extensions = saved, savedProfile, savedExtensions
+	})
+	app.AppPath = filepath.Join(root, "app")
+	app.DataPath = filepath.Join(root, "data")
+	cfg.Profile = "default"
+
+	profile := filepath.Join(app.DataPath, "profile", "default")
+	mkdirs(t, app.AppPath, filepath.Join(app.DataPath, "extensions"), profile)
+	return profile
+}
+
+func readExtensionsStateFile(t *testing.T) extensionsState {
+	t.Helper()
+	states, err := readExtensionsState(filepath.Join(app.DataPath, "extensions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return states
+}
+
+func TestProvisionExtensions(t *testing.T) {
+	profile := setupExtensions(t)
+	distributionXpi := filepath.Join(app.AppPath, "distribution", "extensions", "a@example.com.xpi")
+	profileXpi := filepath.Join(profile, "extensions", "b@example.com.xpi")
+	compatibility := filepath.Join(profile, "compatibility.ini")
+	writeXpi(t, filepath.Join(app.DataPath, "extensions", "a.xpi"), "a@example.com", "1.0")
+	writeXpi(t, filepath.Join(app.DataPath, "extensions", "b.xpi"), "b@example.com", "1.0")
+	writeFiles(t, map[string]string{compatibility: "[Compatibility]"})
+
+	// Install, distribution being the default location
+	cfg.Extensions = []extensionConfig{
+		{Path: "extensions/a.xpi"},
+		{Path: "extensions/b.xpi", Location: extensionLocationProfile},
+	}
+	if err := provisionExtensions(profile); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{distributionXpi, profileXpi} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("extension not installed: %v", err)
+		}
+	}
+	if _, err := os.Stat(compatibility); !os.IsNotExist(err) {
+		t.Error("compatibility.ini kept after installing a distribution extension")
+	}
+	state := readExtensionsStateFile(t)["default"]
+	if state["a@example.com"].Location != extensionLocationDistribution || state["b@example.com"].Location != extensionLocationProfile {
+		t.Errorf("state = %+v, want a in distribution and b in profile", state)
+	}
+
+	// Up to date extensions are not copied again
+	writeFiles(t, map[string]string{compatibility: "[Compatibility]"})
+	if err := provisionExtensions(profile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(compatibility); err != nil {
+		t.Error("compatibility.ini removed without any extension change")
+	}
+
+	// Upgrade
+	writeXpi(t, filepath.Join(app.DataPath, "extensions", "a.xpi"), "a@example.com", "2.0")
+	if err := provisionExtensions(profile); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, err := readXpiManifest(distributionXpi); err != nil || manifest.Version != "2.0" {
+		t.Errorf("installed version = %q (%v), want 2.0", manifest.Version, err)
+	}
+
+	// A failing extension keeps its previous install
+	os.Remove(filepath.Join(app.DataPath, "extensions", "b.xpi"))
+	if err := provisionExtensions(profile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(profileXpi); err != nil {
+		t.Errorf("extension with a missing source removed: %v", err)
+	}
+
+	// Extensions removed from the configuration are uninstalled
+	cfg.Extensions = []extensionConfig{{Path: "extensions/a.xpi"}}
+	if err := provisionExtensions(profile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(profileXpi); !os.IsNotExist(err) {
+		t.Error("extension removed from the configuration still installed")
+	}
+	if _, ok := readExtensionsStateFile(t)["default"]["b@example.com"]; ok {
+		t.Error("extension removed from the configuration still tracked")
+	}
+}
+
+func TestProvisionExtensionsKeepsOthers(t *testing.T) {
+	profile := setupExtensions(t)
+	userXpi := filepath.Join(profile, "extensions", "user@example.com.xpi")
+	mkdirs(t, filepath.Dir(userXpi))
+	writeFiles(t, map[string]string{
+		userXpi: "installed by the user",
+		filepath.Join(app.DataPath, "extensions.json"): `{"work": {"w@example.com": {"version": "1.0", "location": "profile", "source": "extensions/w.xpi"}}}`,
+	})
+
+	cfg.Extensions = nil
+	if err := provisionExtensions(profile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(userXpi); err != nil {
+		t.Errorf("extension installed by the user removed: %v", err)
+	}
+	if _, ok := readExtensionsStateFile(t)["work"]["w@example.com"]; !ok {
+		t.Error("state of another profile removed")
+	}
+}
+
+func TestProvisionExtensionsRemovesDistribution(t *testing.T) {
+	profile := setupExtensions(t)
+	work := filepath.Join(app.DataPath, "profile", "work")
+	distributionXpi := filepath.Join(app.AppPath, "distribution", "extensions", "a@example.com.xpi")
+	writeXpi(t, filepath.Join(app.DataPath, "extensions", "a.xpi"), "a@example.com", "1.0")
+
+	// Both profiles install the extension, and Floorp copies it to each of them
+	cfg.Extensions = []extensionConfig{{Path: "extensions/a.xpi"}}
+	for _, name := range []string{"default", "work"} {
+		cfg.Profile = name
+		folder := filepath.Join(app.DataPath, "profile", name)
+		mkdirs(t, filepath.Join(folder, "extensions"))
+		if err := provisionExtensions(folder); err != nil {
+			t.Fatal(err)
+		}
+		writeXpi(t, filepath.Join(folder, "extensions", "a@example.com.xpi"), "a@example.com", "1.0")
+	}
+
+	// Each profile drops its copy on its next launch
+	cfg.Extensions = nil
+	for _, folder := range []string{profile, work} {
+		cfg.Profile = filepath.Base(folder)
+		if err := provisionExtensions(folder); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{distributionXpi, filepath.Join(folder, "extensions", "a@example.com.xpi")} {
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Errorf("%s kept after removing the extension", file)
+			}
+		}
+	}
+	if states := readExtensionsStateFile(t); len(states) != 0 {
+		t.Errorf("state = %+v, want empty", states)
+	}
+}
+
+func TestReadExtensionsStateLegacy(t *testing.T) {
+	setupExtensions(t)
+	writeFiles(t, map[string]string{
+		filepath.Join(app.DataPath, "extensions.json"): `{"a@example.com": {"version": "1.0", "location": "profile", "source": "extensions/a.xpi"}}`,
+	})
+
+	states := readExtensionsStateFile(t)
+	if states["default"]["a@example.com"].Version != "1.0" {
+		t.Errorf("readExtensionsState() = %+v, want the legacy state in the current profile", states)
+	}
+}
+
+func TestProvisionExtensionUnknownLocation(t *testing.T) {
+	profile := setupExtensions(t)
+	writeXpi(t, filepath.Join(app.DataPath, "extensions", "a.xpi"), "a@example.com", "1.0")
+
+	_, _, err := provisionExtension(profile, extensionConfig{Path: "extensions/a.xpi", Location: "system"}, map[string]installedExtension{})
+	if err == nil {
+		t.Error("provisionExtension() accepted an unknown location")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// goldenConfig holds the golden profile settings. Persist lists the files of
+// the profile kept across resets, as slash-separated glob patterns relative to
+// the profile (e.g. places.sqlite*, bookmarkbackups).
+type goldenConfig struct {
+	Enabled  bool     `yaml:"enabled" mapstructure:"enabled"`
+	Template string   `yaml:"template" mapstructure:"template"`
+	Persist  []string `yaml:"persist" mapstructure:"persist"`
+}
+
+// syncStats counts the changes made by a folder sync.
+type syncStats struct {
+	Copied    int
+	Removed   int
+	Unchanged int
+	Persisted int
+}
+
+// goldenFolder returns the golden snapshot of a profile.
+func goldenFolder() string {
+	return utl.PathJoin(app.DataPath, cfg.Golden.Template, cfg.Profile)
+}
+
+// goldenLocationFile returns the file recording the location of the portable
+// folder when the golden snapshot was taken. It is kept next to the snapshot,
+// so it is never synced into the profile.
+func goldenLocationFile() string {
+	return goldenFolder() + ".location.json"
+}
+
+// readGoldenLocation returns the location the golden snapshot was taken at.
+// Snapshots of previous launchers have no location file: they were relocated
+// on each launch, so they match the location of the previous launch.
+func readGoldenLocation() portableLocation {
+	var location portableLocation
+	raw, err := os.ReadFile(goldenLocationFile())
+	if err == nil {
+		if err = json.Unmarshal(raw, &location); err == nil {
+			return location
+		}
+	}
+	if !os.IsNotExist(err) {
+		log.Warn().Err(err).Msgf("Cannot read %s", goldenLocationFile())
+	}
+
+	location = portableLocation{RootPath: app.Prev.RootPath, AppPath: app.Prev.AppPath, DataPath: app.Prev.DataPath}
+	if location.RootPath == "" {
+		location = currentLocation()
+	}
+	if err := writeGoldenLocation(location); err != nil {
+		log.Error().Err(err).Msg("Cannot write golden snapshot location")
+	}
+	return location
+}
+
+// writeGoldenLocation records the location the golden snapshot was taken at.
+func writeGoldenLocation(location portableLocation) error {
+	raw, err := json.MarshalIndent(location, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(goldenLocationFile(), raw, 0644)
+}
+
+// resetProfile syncs the profile with its golden snapshot, discarding the
+// changes of the previous session except for persisted files. If there is no
+// snapshot yet, the current profile becomes the snapshot.
+func resetProfile(profileFolder string) error {
+	golden := goldenFolder()
+	if !utl.Exists(golden) {
+		// The snapshot is recorded at the current location
+		if err := relocateProfile(profileFolder); err != nil {
+			log.Error().Err(err).Msg("Cannot fix profile paths")
+		}
+		log.Info().Msgf("Creating golden snapshot %s from profile", golden)
+		if err := copyDir(profileFolder, golden); err != nil {
+			return errors.Wrap(err, "Cannot create golden snapshot")
+		}
+		return writeGoldenLocation(currentLocation())
+	}
+	location := readGoldenLocation()
+
+	log.Info().Msgf("Resetting profile from golden snapshot %s", golden)
+	stats, err := syncFolder(golden, profileFolder, cfg.Golden.Persist)
+	if err != nil {
+		return errors.Wrap(err, "Cannot sync golden snapshot")
+	}
+	log.Info().Msgf("Profile reset: %d copied, %d removed, %d unchanged, %d persisted",
+		stats.Copied, stats.Removed, stats.Unchanged, stats.Persisted)
+
+	// The snapshot may have been taken at another location, only its copy is
+	// fixed so the snapshot itself is never written to. Persisted files come
+	// from the previous session instead. Each file is relocated once, the
+	// profile then matches the current location.
+	persisted := func(rel string) bool {
+		return isPersisted(rel, cfg.Golden.Persist)
+	}
+	synced := func(rel string) bool {
+		return !persisted(rel)
+	}
+	if err := relocateProfileFrom(profileFolder, relocationsFrom(location), synced); err != nil {
+		log.Error().Err(err).Msg("Cannot fix profile paths of golden snapshot")
+	}
+	if err := relocateProfileFrom(profileFolder, relocationPaths(), persisted); err != nil {
+		log.Error().Err(err).Msg("Cannot fix profile paths of persisted files")
+	}
+	markProfileRelocated()
+
+	return nil
+}
+
+// syncFolder makes dst a copy of src. Only files whose size or modification
+// time differ are copied, files not in src are removed, and files matching a
+// persist pattern are left untouched once they exist in dst.
+func syncFolder(src string, dst string, persist []string) (syncStats, error) {
+	var stats syncStats
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return stats, err
+	}
+
+	// Remove what is not in src, deepest paths first
+	var extra []string
+	err := filepath.WalkDir(dst, func(dstPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dst, dstPath)
+		if err != nil || rel == "." {
+			return err
+		}
+		if isPersisted(rel, persist) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		srcInfo, err := os.Stat(filepath.Join(src, rel))
+		if err != nil || srcInfo.IsDir() != entry.IsDir() {
+			extra = append(extra, dstPath)
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(extra)))
+	for _, dstPath := range extra {
+		if err := os.RemoveAll(dstPath); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	// Copy what changed
+	err = filepath.WalkDir(src, func(srcPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, srcPath)
+		if err != nil || rel == "." {
+			return err
+		}
+		dstPath := filepath.Join(dst, rel)
+
+		srcInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(dstPath, srcInfo.Mode().Perm()|0700)
+		}
+
+		dstInfo, err := os.Stat(dstPath)
+		if err == nil && isPersisted(rel, persist) {
+			stats.Persisted++
+			return nil
+		}
+		if err == nil && dstInfo.Size() == srcInfo.Size() && dstInfo.ModTime().Equal(srcInfo.ModTime()) {
+			stats.Unchanged++
+			return nil
+		}
+
+		if err := copyFile(srcPath, dstPath); err != nil {
+			return err
+		}
+		stats.Copied++
+		return os.Chtimes(dstPath, srcInfo.ModTime(), srcInfo.ModTime())
+	})
+
+	return stats, err
+}
+
+// isPersisted reports whether a path relative to the profile, or one of its
+// parent folders, matches a persist pattern.
+func isPersisted(rel string, persist []string) bool {
+	rel = strings.ToLower(filepath.ToSlash(rel))
+	for _, pattern := range persist {
+		pattern = strings.ToLower(strings.Trim(filepath.ToSlash(pattern), "/"))
+		for candidate := rel; candidate != "."; candidate = path.Dir(candidate) {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
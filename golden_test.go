@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResetProfile(t *testing.T) {
+	const templatePrefs = `user_pref("browser.download.dir", "D:\\Floorp\\data\\downloads");` + "\n"
+	prevLocation := portableLocation{RootPath: `D:\Floorp`, AppPath: `D:\Floorp\app`, DataPath: `D:\Floorp\data`}
+
+	tests := []struct {
+		name     string
+		location *portableLocation
+		prev     portableLocation
+	}{
+		{name: "recorded location", location: &prevLocation},
+		{name: "snapshot of previous launcher", prev: prevLocation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			saved, golden := *app, cfg.Golden
+			t.Cleanup(func() {
+				*app, cfg.Golden = saved, golden
+			})
+			app.RootPath = root
+			app.AppPath = filepath.Join(root, "app")
+			app.DataPath = filepath.Join(root, "data")
+			app.Prev.RootPath, app.Prev.AppPath, app.Prev.DataPath = tt.prev.RootPath, tt.prev.AppPath, tt.prev.DataPath
+			cfg.Golden.Template = "golden"
+			cfg.Golden.Persist = []string{"places.sqlite*"}
+
+			template := goldenFolder()
+			profile := filepath.Join(app.DataPath, "profile")
+			mkdirs(t, template, profile)
+			writeFiles(t, map[string]string{
+				filepath.Join(template, "prefs.js"):        templatePrefs,
+				filepath.Join(template, "places.sqlite"):   "template history",
+				filepath.Join(profile, "prefs.js"):         "session prefs",
+				filepath.Join(profile, "places.sqlite"):    "session history",
+				filepath.Join(profile, "cookies.sqlite"):   "session cookies",
+				filepath.Join(template, "extensions.json"): `{"path":"D:\\Floorp\\data\\profile\\extensions\\a.xpi"}`,
+			})
+			if tt.location != nil {
+				if err := writeGoldenLocation(*tt.location); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := resetProfile(profile); err != nil {
+				t.Fatal(err)
+			}
+
+			quoted, _ := json.Marshal(strings.ReplaceAll(root, "/", `\`))
+			escapedRoot := strings.Trim(string(quoted), `"`)
+			want := map[string]string{
+				filepath.Join(profile, "prefs.js"):         strings.ReplaceAll(templatePrefs, `D:\\Floorp`, escapedRoot),
+				filepath.Join(profile, "places.sqlite"):    "session history",
+				filepath.Join(profile, "extensions.json"):  `{"path":"` + escapedRoot + `\\data\\profile\\extensions\\a.xpi"}`,
+				filepath.Join(template, "prefs.js"):        templatePrefs,
+				filepath.Join(template, "extensions.json"): `{"path":"D:\\Floorp\\data\\profile\\extensions\\a.xpi"}`,
+			}
+			for file, content := range want {
+				raw, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(raw) != content {
+					t.Errorf("%s = %q, want %q", file, raw, content)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(profile, "cookies.sqlite")); !os.IsNotExist(err) {
+				t.Errorf("cookies.sqlite was not removed: %v", err)
+			}
+			if got := readGoldenLocation(); got != prevLocation {
+				t.Errorf("readGoldenLocation = %+v, want %+v", got, prevLocation)
+			}
+		})
+	}
+}
+
+func TestResetProfileMovedRoot(t *testing.T) {
+	saved, golden := *app, cfg.Golden
+	t.Cleanup(func() {
+		*app, cfg.Golden = saved, golden
+	})
+
+	// The portable folder moved inside its previous location, a path relocated
+	// twice would get the new subfolder twice
+	prevRoot := t.TempDir()
+	prevLocation := portableLocation{RootPath: prevRoot, AppPath: filepath.Join(prevRoot, "app"), DataPath: filepath.Join(prevRoot, "data")}
+	root := filepath.Join(prevRoot, "portable")
+	app.RootPath = root
+	app.AppPath = filepath.Join(root, "app")
+	app.DataPath = filepath.Join(root, "data")
+	app.Prev.RootPath, app.Prev.AppPath, app.Prev.DataPath = prevLocation.RootPath, prevLocation.AppPath, prevLocation.DataPath
+	cfg.Golden.Template = "golden"
+	cfg.Golden.Persist = []string{"handlers.json"}
+
+	template := goldenFolder()
+	profile := filepath.Join(app.DataPath, "profile")
+	mkdirs(t, template, profile)
+	prevHandler := filepath.ToSlash(filepath.Join(prevRoot, "data", "handler.exe"))
+	writeFiles(t, map[string]string{
+		filepath.Join(template, "pkcs11.txt"):    "library=" + filepath.Join(prevRoot, "app", "softokn3.dll"),
+		filepath.Join(template, "handlers.json"): `{"path":"template"}`,
+		filepath.Join(profile, "handlers.json"):  `{"path":"` + prevHandler + `"}`,
+	})
+	if err := writeGoldenLocation(prevLocation); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := resetProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	// The launch then prepares the profile
+	if err := relocateProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		filepath.Join(profile, "pkcs11.txt"):    "library=" + filepath.Join(root, "app", "softokn3.dll"),
+		filepath.Join(profile, "handlers.json"): `{"path":"` + filepath.ToSlash(filepath.Join(root, "data", "handler.exe")) + `"}`,
+		filepath.Join(template, "pkcs11.txt"):   "library=" + filepath.Join(prevRoot, "app", "softokn3.dll"),
+	}
+	for file, content := range want {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != content {
+			t.Errorf("%s = %q, want %q", file, raw, content)
+		}
+	}
+}
+
+func TestIsPersisted(t *testing.T) {
+	persist := []string{"places.sqlite*", "bookmarkbackups", "/storage/default/"}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"places.sqlite", true},
+		{"places.sqlite-wal", true},
+		{"Places.SQLite", true},
+		{"bookmarkbackups", true},
+		{filepath.Join("bookmarkbackups", "bookmarks-2024.jsonlz4"), true},
+		{filepath.Join("storage", "default", "https+++example.com"), true},
+		{"storage", false},
+		{"prefs.js", false},
+		{filepath.Join("backup", "places.sqlite"), false},
+	}
+	for _, tt := range tests {
+		if got := isPersisted(tt.rel, persist); got != tt.want {
+			t.Errorf("isPersisted(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
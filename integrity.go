@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+	"github.com/portapps/portapps/v3/pkg/win"
+)
+
+// generatedAppFiles are written to the app folder by the launcher on each
+// launch and are left out of the manifest, as well as the extensions copied to
+// distribution/extensions.
+var generatedAppFiles = map[string]bool{
+	"portapps.cfg":                true,
+	"defaults/pref/autoconfig.js": true,
+	"distribution/policies.json":  true,
+}
+
+// appManifest lists the files of the installed app with their size and hash.
+// Paths are slash-separated and relative to the app folder.
+type appManifest struct {
+	Version string                  `json:"version"`
+	Created time.Time               `json:"created"`
+	Files   map[string]manifestFile `json:"files"`
+}
+
+// manifestFile is a file of the app manifest.
+type manifestFile struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// appIntegrity is the result of a check of the app folder against its
+// manifest.
+type appIntegrity struct {
+	Missing  []string
+	Modified []string
+}
+
+// ok reports whether the app folder matches its manifest.
+func (r appIntegrity) ok() bool {
+	return len(r.Missing) == 0 && len(r.Modified) == 0
+}
+
+// String lists the missing and modified files.
+func (r appIntegrity) String() string {
+	var sb strings.Builder
+	for _, file := range r.Missing {
+		fmt.Fprintf(&sb, "missing   %s\n", file)
+	}
+	for _, file := range r.Modified {
+		fmt.Fprintf(&sb, "modified  %s\n", file)
+	}
+	return sb.String()
+}
+
+// appManifestFile returns the manifest of the app folder. It is kept in the
+// data folder so it survives the replacement of the app folder.
+func appManifestFile() string {
+	return utl.PathJoin(app.DataPath, "app-manifest.json")
+}
+
+// buildAppManifest hashes the files of an app folder.
+func buildAppManifest(folder string, version string) (*appManifest, error) {
+	manifest := &appManifest{
+		Version: version,
+		Created: time.Now(),
+		Files:   map[string]manifestFile{},
+	}
+
+	err := filepath.WalkDir(folder, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if lower := strings.ToLower(rel); generatedAppFiles[lower] || strings.HasPrefix(lower, "distribution/extensions/") {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		manifest.Files[rel] = manifestFile{Size: info.Size(), SHA256: hash}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot hash %s", folder)
+	}
+
+	return manifest, nil
+}
+
+// readAppManifest reads the manifest of the app folder. A missing manifest
+// returns nil without error.
+func readAppManifest() (*appManifest, error) {
+	raw, err := os.ReadFile(appManifestFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var manifest appManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "Cannot parse %s", appManifestFile())
+	}
+	return &manifest, nil
+}
+
+// writeAppManifest saves the manifest of the app folder.
+func writeAppManifest(manifest *appManifest) error {
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(appManifestFile(), raw, 0644)
+}
+
+// checkAppIntegrity compares an app folder with a manifest. The quick check
+// only compares file sizes, the full check also compares hashes.
+func checkAppIntegrity(manifest *appManifest, folder string, full bool) appIntegrity {
+	var result appIntegrity
+
+	files := make([]string, 0, len(manifest.Files))
+	for file := range manifest.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		expected := manifest.Files[file]
+		path := filepath.Join(folder, filepath.FromSlash(file))
+		info, err := os.Stat(path)
+		if err != nil {
+			result.Missing = append(result.Missing, file)
+			continue
+		}
+		if info.Size() != expected.Size {
+			result.Modified = append(result.Modified, file)
+			continue
+		}
+		if full {
+			if hash, err := fileSHA256(path); err != nil || hash != expected.SHA256 {
+				result.Modified = append(result.Modified, file)
+			}
+		}
+	}
+
+	return result
+}
+
+// installedAppManifest returns the manifest of the installed version and the
+// version, or a nil manifest if there is none. Manifests are only made from
+// the files of a release, when the launcher installs Floorp or on request with
+// --verify, so a damaged app folder is never taken as the reference.
+func installedAppManifest() (*appManifest, string, error) {
+	version, err := getPortappVersion()
+	if err != nil {
+		return nil, "", err
+	}
+
+	manifest, err := readAppManifest()
+	if err != nil {
+		return nil, version, err
+	}
+	if manifest == nil || manifest.Version != version {
+		return nil, version, nil
+	}
+	return manifest, version, nil
+}
+
+// printAppIntegrity writes the result of a full check of the app folder to w
+// and offers to repair it. Without a manifest for the installed version, one
+// is made from a fresh download of its release.
+func printAppIntegrity(w io.Writer) error {
+	manifest, version, err := installedAppManifest()
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		fmt.Fprintf(w, "No manifest for version %s, downloading the release to create one\n", version)
+		if manifest, err = releaseAppManifest(version); err != nil {
+			return err
+		}
+		if err := writeAppManifest(manifest); err != nil {
+			return errors.Wrap(err, "Cannot write app manifest")
+		}
+		fmt.Fprintf(w, "Created %s\n", appManifestFile())
+	}
+
+	result := checkAppIntegrity(manifest, app.AppPath, true)
+	if result.ok() {
+		fmt.Fprintf(w, "App folder %s is intact\n", app.AppPath)
+		return nil
+	}
+
+	fmt.Fprintf(w, "App folder %s is damaged:\n%s", app.AppPath, result)
+	if instanceRunning() {
+		fmt.Fprintln(w, "Close Floorp to repair it")
+		return nil
+	}
+	return offerAppRepair(result)
+}
+
+// releaseAppManifest makes the manifest of a version from the files of its
+// release, downloaded and extracted to a temporary folder.
+func releaseAppManifest(version string) (*appManifest, error) {
+	tempDir, err := os.MkdirTemp("", "floorp-verify")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	installer := filepath.Join(tempDir, "floorp-installer.exe")
+	if err := downloadFile(releaseDownloadURL(releasePageURL(version)), installer); err != nil {
+		return nil, errors.Wrapf(err, "Cannot download version %s", version)
+	}
+	extractDir := filepath.Join(tempDir, "extract")
+	if err := extract7zArchive(installer, extractDir); err != nil {
+		return nil, err
+	}
+	appDir, err := findAppDir(extractDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildAppManifest(appDir, version)
+}
+
+// quickCheckApp compares the app folder with its manifest on startup, only
+// checking that files exist with the expected size.
+func quickCheckApp() {
+	manifest, version, err := installedAppManifest()
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot check app folder")
+		return
+	}
+	if manifest == nil {
+		log.Warn().Msgf("No manifest for version %s, cannot verify app folder, run with --verify to create one", version)
+		return
+	}
+
+	result := checkAppIntegrity(manifest, app.AppPath, false)
+	if result.ok() {
+		return
+	}
+
+	log.Warn().Msgf("App folder is damaged:\n%s", result)
+	if err := offerAppRepair(result); err != nil {
+		log.Error().Err(err).Msg("Cannot repair app folder")
+	}
+}
+
+// offerAppRepair asks the user to repair the app folder by downloading the
+// installed version again.
+func offerAppRepair(result appIntegrity) error {
+	version, err := getPortappVersion()
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf(
+		"%d file(s) of %s are missing and %d file(s) are modified, e.g. by an antivirus or a faulty drive.\n\n"+
+			"Do you want to repair it by downloading version %s again?",
+		len(result.Missing), app.Name, len(result.Modified), version)
+	answer, err := win.MsgBox(
+		fmt.Sprintf("%s repair", app.Name),
+		message,
+		win.MsgBoxBtnYesNo|win.MsgBoxIconWarning)
+	if err != nil {
+		return errors.Wrap(err, "Cannot create dialog box")
+	}
+	if answer != 6 { // IDYES
+		log.Info().Msg("App repair declined")
+		return nil
+	}
+
+	log.Info().Msgf("Repairing app folder with version %s", version)
+	return downloadAndUpdate(releaseDownloadURL(releasePageURL(version)), version)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeAppFolder writes the files of an app folder, keyed by slash-separated
+// paths.
+func writeAppFolder(t *testing.T, folder string, files map[string]string) {
+	t.Helper()
+	for file, content := range files {
+		path := filepath.Join(folder, filepath.FromSlash(file))
+		mkdirs(t, filepath.Dir(path))
+		writeFiles(t, map[string]string{path: content})
+	}
+}
+
+func TestBuildAppManifest(t *testing.T) {
+	folder := t.TempDir()
+	writeAppFolder(t, folder, map[string]string{
+		"floorp.exe":                          "MZ",
+		"browser/omni.ja":                     "omni",
+		"distribution/distribution.ini":       "[Global]",
+		"portapps.cfg":                        "generated",
+		"defaults/pref/autoconfig.js":         "generated",
+		"Distribution/Policies.json":          "generated",
+		"distribution/extensions/a@b.xpi":     "extension",
+		"distribution/extensions/c@d/install": "extension",
+	})
+
+	manifest, err := buildAppManifest(folder, "11.20.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != "11.20.0" {
+		t.Errorf("Version = %s, want 11.20.0", manifest.Version)
+	}
+
+	var files []string
+	for file := range manifest.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	if want := []string{"browser/omni.ja", "distribution/distribution.ini", "floorp.exe"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("manifest files = %v, want %v", files, want)
+	}
+
+	// sha256 of "MZ"
+	want := manifestFile{Size: 2, SHA256: "9b8db510ef42b8ed54a3712636fda55a4f8cfcd5493e20b74ab00cd4f3979f2d"}
+	if got := manifest.Files["floorp.exe"]; got != want {
+		t.Errorf("floorp.exe = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckAppIntegrity(t *testing.T) {
+	files := map[string]string{
+		"floorp.exe":         "MZ",
+		"browser/omni.ja":    "omni",
+		"xul.dll":            "xul",
+		"application.ini":    "[App]",
+		"defaults/pref/a.js": "pref",
+	}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, folder string)
+		full   bool
+		want   appIntegrity
+	}{
+		{
+			name: "intact",
+			full: true,
+		},
+		{
+			name: "generated files",
+			change: func(t *testing.T, folder string) {
+				writeAppFolder(t, folder, map[string]string{
+					"portapps.cfg":                    "generated",
+					"distribution/policies.json":      "{}",
+					"distribution/extensions/a@b.xpi": "extension",
+				})
+			},
+			full: true,
+		},
+		{
+			name: "missing and truncated",
+			change: func(t *testing.T, folder string) {
+				if err := os.Remove(filepath.Join(folder, "xul.dll")); err != nil {
+					t.Fatal(err)
+				}
+				writeAppFolder(t, folder, map[string]string{"browser/omni.ja": "om"})
+			},
+			want: appIntegrity{Missing: []string{"xul.dll"}, Modified: []string{"browser/omni.ja"}},
+		},
+		{
+			name: "same size quick check",
+			change: func(t *testing.T, folder string) {
+				writeAppFolder(t, folder, map[string]string{"application.ini": "[Bad]"})
+			},
+		},
+		{
+			name: "same size full check",
+			change: func(t *testing.T, folder string) {
+				writeAppFolder(t, folder, map[string]string{"application.ini": "[Bad]"})
+			},
+			full: true,
+			want: appIntegrity{Modified: []string{"application.ini"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := t.TempDir()
+			writeAppFolder(t, folder, files)
+			manifest, err := buildAppManifest(folder, "11.20.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change != nil {
+				tt.change(t, folder)
+			}
+
+			got := checkAppIntegrity(manifest, folder, tt.full)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkAppIntegrity() = %+v, want %+v", got, tt.want)
+			}
+			if got.ok() != (len(tt.want.Missing) == 0 && len(tt.want.Modified) == 0) {
+				t.Errorf("ok() = %v", got.ok())
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// kioskConfig holds the kiosk mode settings.
+type kioskConfig struct {
+	Enabled      bool   `yaml:"enabled" mapstructure:"enabled"`
+	StartPage    string `yaml:"start_page" mapstructure:"start_page"`
+	Reset        bool   `yaml:"reset" mapstructure:"reset"`
+	Restart      bool   `yaml:"restart" mapstructure:"restart"`
+	RestartDelay int    `yaml:"restart_delay" mapstructure:"restart_delay"`
+}
+
+// Restart backoff of the kiosk mode. A run shorter than kioskMinRun is a rapid
+// restart, each consecutive one doubles the restart delay up to
+// kioskMaxRestartDelay.
+const (
+	kioskMinRun          = 30 * time.Second
+	kioskMaxRestartDelay = 5 * time.Minute
+)
+
+// kioskRestartDelay returns the delay before restarting Floorp after the
+// given number of consecutive rapid restarts, so a Floorp failing on startup
+// is not restarted in a tight loop.
+func kioskRestartDelay(delay time.Duration, rapidRestarts int) time.Duration {
+	if rapidRestarts == 0 {
+		return delay
+	}
+	if delay < time.Second {
+		delay = time.Second
+	}
+	for i := 0; i < rapidRestarts && delay < kioskMaxRestartDelay; i++ {
+		delay *= 2
+	}
+	if delay > kioskMaxRestartDelay {
+		return kioskMaxRestartDelay
+	}
+	return delay
+}
+
+// kioskDownloadsFolder returns the folder downloads are confined to in kiosk
+// mode.
+func kioskDownloadsFolder() string {
+	return utl.PathJoin(app.DataPath, "kiosk", "downloads")
+}
+
+// kioskArgs returns the arguments starting Floorp in kiosk mode.
+func kioskArgs() []string {
+	args := []string{"--kiosk"}
+	if cfg.Kiosk.StartPage != "" {
+		args = append(args, cfg.Kiosk.StartPage)
+	}
+	return args
+}
+
+// kioskPolicies returns the policies locking Floorp down in kiosk mode.
+// Downloads cannot be disabled: Floorp, like Firefox, has neither a policy nor
+// a preference blocking them (DownloadRestrictions is Chromium only), so they
+// are confined to a folder emptied on each launch.
+func kioskPolicies(kiosk kioskConfig) map[string]interface{} {
+	policies := map[string]interface{}{
+		"BlockAboutAddons":      true,
+		"BlockAboutConfig":      true,
+		"BlockAboutProfiles":    true,
+		"BlockAboutSupport":     true,
+		"DisableDeveloperTools": true,
+		"DisableProfileRefresh": true,
+		// Also blocks safe mode with the Shift key. Nobody answers prompts on
+		// a kiosk, so the launcher does not offer safe mode after crashes
+		// either and restarts Floorp from a fresh profile instead
+		"DisableSafeMode":           true,
+		"DownloadDirectory":         kioskDownloadsFolder(),
+		"PromptForDownloadLocation": false,
+		"OverrideFirstRunPage":      "",
+		"OverridePostUpdatePage":    "",
+	}
+	if kiosk.StartPage != "" {
+		policies["Homepage"] = map[string]interface{}{
+			"URL":       kiosk.StartPage,
+			"Locked":    true,
+			"StartPage": "homepage-locked",
+		}
+	}
+	return policies
+}
+
+// setupKiosk prepares a kiosk session: the profile is reset from its golden
+// snapshot and previous downloads are removed. Both are skipped when another
+// instance is running, as it holds the profile and downloads open.
+func setupKiosk(profileFolder string, otherInstance bool) {
+	log.Warn().Msgf("Downloads cannot be disabled in kiosk mode, they are confined to %s", kioskDownloadsFolder())
+	if otherInstance {
+		log.Warn().Msg("Another instance is running, the kiosk profile is not reset")
+		return
+	}
+
+	if cfg.Kiosk.Reset {
+		if err := resetProfile(profileFolder); err != nil {
+			log.Error().Err(err).Msg("Cannot reset kiosk profile")
+		}
+	}
+
+	downloadsFolder := kioskDownloadsFolder()
+	if err := os.RemoveAll(downloadsFolder); err != nil {
+		log.Error().Err(err).Msgf("Cannot empty %s", downloadsFolder)
+	}
+	utl.CreateFolder(downloadsFolder)
+}
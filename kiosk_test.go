@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKioskRestartDelay(t *testing.T) {
+	tests := []struct {
+		delay         time.Duration
+		rapidRestarts int
+		want          time.Duration
+	}{
+		{2 * time.Second, 0, 2 * time.Second},
+		{0, 0, 0},
+		{2 * time.Second, 1, 4 * time.Second},
+		{2 * time.Second, 3, 16 * time.Second},
+		{0, 1, 2 * time.Second},
+		{2 * time.Second, 10, kioskMaxRestartDelay},
+		{2 * time.Second, 1000, kioskMaxRestartDelay},
+		{10 * time.Minute, 1, kioskMaxRestartDelay},
+	}
+	for _, tt := range tests {
+		if got := kioskRestartDelay(tt.delay, tt.rapidRestarts); got != tt.want {
+			t.Errorf("kioskRestartDelay(%s, %d) = %s, want %s", tt.delay, tt.rapidRestarts, got, tt.want)
+		}
+	}
+}
+
+func TestKioskPolicies(t *testing.T) {
+	saved := *app
+	t.Cleanup(func() {
+		*app = saved
+	})
+	app.DataPath = filepath.Join(t.TempDir(), "data")
+
+	locked := map[string]interface{}{
+		"BlockAboutAddons":          true,
+		"BlockAboutConfig":          true,
+		"BlockAboutProfiles":        true,
+		"BlockAboutSupport":         true,
+		"DisableDeveloperTools":     true,
+		"DisableProfileRefresh":     true,
+		"DisableSafeMode":           true,
+		"DownloadDirectory":         filepath.Join(app.DataPath, "kiosk", "downloads"),
+		"PromptForDownloadLocation": false,
+		"OverrideFirstRunPage":      "",
+		"OverridePostUpdatePage":    "",
+	}
+	tests := []struct {
+		name     string
+		kiosk    kioskConfig
+		homepage interface{}
+	}{
+		{
+			name:  "no start page",
+			kiosk: kioskConfig{Enabled: true},
+		},
+		{
+			name:  "start page",
+			kiosk: kioskConfig{Enabled: true, StartPage: "https://kiosk.example.com"},
+			homepage: map[string]interface{}{
+				"URL":       "https://kiosk.example.com",
+				"Locked":    true,
+				"StartPage": "homepage-locked",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := map[string]interface{}{}
+			for key, value := range locked {
+				want[key] = value
+			}
+			if tt.homepage != nil {
+				want["Homepage"] = tt.homepage
+			}
+			if got := kioskPolicies(tt.kiosk); !reflect.DeepEqual(got, want) {
+				t.Errorf("kioskPolicies() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSetupKiosk(t *testing.T) {
+	tests := []struct {
+		name          string
+		reset         bool
+		otherInstance bool
+		wantPrefs     string
+		wantDownloads bool
+	}{
+		{name: "reset", reset: true, wantPrefs: "golden prefs"},
+		{name: "no reset", wantPrefs: "session prefs"},
+		{name: "other instance", reset: true, otherInstance: true, wantPrefs: "session prefs", wantDownloads: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			saved, golden, kiosk := *app, cfg.Golden, cfg.Kiosk
+			t.Cleanup(func() {
+				*app, cfg.Golden, cfg.Kiosk = saved, golden, kiosk
+			})
+			app.RootPath = root
+			app.AppPath = filepath.Join(root, "app")
+			app.DataPath = filepath.Join(root, "data")
+			cfg.Golden.Template = "golden"
+			cfg.Kiosk = kioskConfig{Enabled: true, Reset: tt.reset}
+
+			profile := filepath.Join(app.DataPath, "profile")
+			download := filepath.Join(kioskDownloadsFolder(), "file.pdf")
+			mkdirs(t, goldenFolder(), profile, kioskDownloadsFolder())
+			writeFiles(t, map[string]string{
+				filepath.Join(goldenFolder(), "prefs.js"): "golden prefs",
+				filepath.Join(profile, "prefs.js"):        "session prefs",
+				download:                                  "downloaded",
+			})
+			if err := writeGoldenLocation(currentLocation()); err != nil {
+				t.Fatal(err)
+			}
+
+			setupKiosk(profile, tt.otherInstance)
+
+			raw, err := os.ReadFile(filepath.Join(profile, "prefs.js"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != tt.wantPrefs {
+				t.Errorf("prefs.js = %q, want %q", raw, tt.wantPrefs)
+			}
+			_, err = os.Stat(download)
+			if kept := !os.IsNotExist(err); kept != tt.wantDownloads {
+				t.Errorf("download kept = %v, want %v", kept, tt.wantDownloads)
+			}
+			if _, err := os.Stat(kioskDownloadsFolder()); err != nil {
+				t.Errorf("downloads folder missing: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// hostSession holds the changes a launch makes on the host. They are undone
+// each time Floorp exits, as the kiosk restart loop never returns.
+type hostSession struct {
+	traces    *hostTraces
+	shortcuts []string
+}
+
+// open creates the session shortcuts.
+func (s *hostSession) open() {
+	s.shortcuts = createShortcuts()
+}
+
+// close records the location of the portable folder, then removes the session
+// shortcuts and the traces left on the host.
+func (s *hostSession) close() {
+	app.Close()
+	removeShortcuts(s.shortcuts)
+	s.shortcuts = nil
+	if s.traces != nil {
+		cleanupHost(s.traces)
+	}
+}
+
+// launchFloorp runs Floorp until it exits and logs how the run ended. After
+// repeated crashes, a restart in safe mode is offered. In kiosk mode with
+// restart enabled, Floorp is started again from a fresh profile each time it
+// exits. The profile is not reset if otherInstance reports another instance
+// using it. The session is closed after each run and opened again on kiosk
+// restarts.
+func launchFloorp(profileFolder string, args []string, otherInstance bool, session *hostSession) {
+	env := childEnv(profileFolder)
+	safeMode := false
+	rapidRestarts := 0
+	for {
+		// The launcher process of floorp.exe exits as soon as the browser is
+		// started unless it is asked to wait, which would hide crashes and
+		// make the kiosk restart a running browser
+		runArgs := append([]string{"-wait-for-browser"}, args...)
+		if safeMode {
+			runArgs = append([]string{"-safe-mode"}, runArgs...)
+		}
+
+		record, err := superviseFloorp(runArgs, env)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Command failed")
+		}
+		record.SafeMode = safeMode
+		crashes := recordRun(record)
+		logRun(record, crashes)
+		inventoryCrashDumps(record)
+		session.close()
+
+		if cfg.Kiosk.Enabled && cfg.Kiosk.Restart {
+			if record.Duration < kioskMinRun.Seconds() {
+				rapidRestarts++
+			} else {
+				rapidRestarts = 0
+			}
+			delay := kioskRestartDelay(time.Duration(cfg.Kiosk.RestartDelay)*time.Second, rapidRestarts)
+			log.Warn().Msgf("Floorp exited with code %d, restarting in %s", record.ExitCode, delay)
+			time.Sleep(delay)
+			setupKiosk(profileFolder, otherInstance)
+			prepareProfile(profileFolder)
+			session.open()
+			continue
+		}
+
+		if record.crashed() && !safeMode && !cfg.Kiosk.Enabled &&
+			cfg.Crash.SafeModeAfter > 0 && crashes >= cfg.Crash.SafeModeAfter && confirmSafeMode(crashes) {
+			log.Info().Msg("Restarting Floorp in safe mode")
+			safeMode = true
+			session.open()
+			continue
+		}
+		return
+	}
+}
+
+// prepareProfile fixes the profile for the current location and applies the
+// search engines and extensions of the configuration.
+func prepareProfile(profileFolder string) {
+	// Fix profile paths
+	if err := relocateProfile(profileFolder); err != nil {
+		log.Error().Err(err).Msg("Cannot fix profile paths")
+	}
+
+	// Search engines
+	if err := updateSearchSettings(profileFolder); err != nil {
+		log.Error().Err(err).Msg("Cannot update search settings")
+	}
+
+	// Extensions
+	if err := provisionExtensions(profileFolder); err != nil {
+		log.Error().Err(err).Msg("Cannot provision extensions")
+	}
+}
+
+// runFloorp starts Floorp the way app.Launch does, with the env environment,
+// and waits for it to exit. Unlike app.Launch, a non-zero exit code is
+// returned instead of being fatal.
+func runFloorp(args []string, env []string) (int, error) {
+	if !utl.Exists(app.Process) {
+		return -1, errors.Errorf("Application not found in %s", app.Process)
+	}
+
+	common := app.Config().Common
+	jArgs := append(append(append([]string{}, common.Args...), args...), app.Args...)
+	execute := exec.Command(app.Process, jArgs...)
+	execute.Dir = app.WorkingDir
+	execute.Env = env
+
+	if !common.DisableLog {
+		logfile, err := os.OpenFile(utl.PathJoin(app.RootPath, "log", fmt.Sprintf("%s.log", app.ID)), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.Error().Err(err).Msg("Cannot open log file for Floorp output")
+		} else {
+			defer logfile.Close()
+			execute.Stdout = logfile
+			execute.Stderr = logfile
+		}
+	}
+
+	log.Info().Msgf("Launching %s", app.Name)
+	log.Info().Msgf("Exec %s %s", app.Process, strings.Join(jArgs, " "))
+	err := execute.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
@@ -1,5 +1,3 @@
-//go:generate go install -v github.com/kevinburke/go-bindata/v4/go-bindata
-//go:generate go-bindata -prefix res/ -pkg assets -o assets/assets.go res/Floorp.lnk
 //go:generate go install -v github.com/josephspurrier/goversioninfo/cmd/goversioninfo
 //go:generate goversioninfo -icon=res/papp.ico -manifest=res/papp.manifest
 package main
@@ -11,60 +9,182 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
-	"github.com/Floorp-Projects/Floorp-Portable-v2/assets"
-	"github.com/Jeffail/gabs"
 	"github.com/bodgit/sevenzip"
 	"github.com/pkg/errors"
 	"github.com/portapps/portapps/v3"
 	"github.com/portapps/portapps/v3/pkg/log"
 	"github.com/portapps/portapps/v3/pkg/mutex"
-	"github.com/portapps/portapps/v3/pkg/shortcut"
 	"github.com/portapps/portapps/v3/pkg/utl"
 	"github.com/portapps/portapps/v3/pkg/win"
 )
 
 type config struct {
-	Profile           string `yaml:"profile" mapstructure:"profile"`
-	MultipleInstances bool   `yaml:"multiple_instances" mapstructure:"multiple_instances"`
-	Cleanup           bool   `yaml:"cleanup" mapstructure:"cleanup"`
-	CheckForUpdates   bool   `yaml:"check_for_updates" mapstructure:"check_for_updates"`
-	UpdateURL         string `yaml:"update_url" mapstructure:"update_url"`
+	Profile           string                  `yaml:"profile" mapstructure:"profile"`
+	MultipleInstances bool                    `yaml:"multiple_instances" mapstructure:"multiple_instances"`
+	Cleanup           bool                    `yaml:"cleanup" mapstructure:"cleanup"`
+	CleanupPaths      []string                `yaml:"cleanup_paths" mapstructure:"cleanup_paths"`
+	CleanupRegistry   []string                `yaml:"cleanup_registry" mapstructure:"cleanup_registry"`
+	CleanupDryRun     bool                    `yaml:"cleanup_dry_run" mapstructure:"cleanup_dry_run"`
+	CleanupReport     bool                    `yaml:"cleanup_report" mapstructure:"cleanup_report"`
+	CheckForUpdates   bool                    `yaml:"check_for_updates" mapstructure:"check_for_updates"`
+	VerifyApp         bool                    `yaml:"verify_app" mapstructure:"verify_app"`
+	UpdateURL         string                  `yaml:"update_url" mapstructure:"update_url"`
+	Policies          map[string]interface{}  `yaml:"policies" mapstructure:"policies"`
+	PolicyOverrides   map[string]interface{}  `yaml:"policy_overrides" mapstructure:"policy_overrides"`
+	StrictPolicies    bool                    `yaml:"strict_policies" mapstructure:"strict_policies"`
+	StrictPortable    bool                    `yaml:"strict_portable" mapstructure:"strict_portable"`
+	StrictDownloads   string                  `yaml:"strict_portable_downloads" mapstructure:"strict_portable_downloads"`
+	Env               map[string]string       `yaml:"env" mapstructure:"env"`
+	EnvUnset          []string                `yaml:"env_unset" mapstructure:"env_unset"`
+	Args              []string                `yaml:"args" mapstructure:"args"`
+	Presets           map[string]presetConfig `yaml:"presets" mapstructure:"presets"`
+	Prefs             prefsConfig             `yaml:"prefs" mapstructure:"prefs"`
+	Search            searchConfig            `yaml:"search" mapstructure:"search"`
+	Extensions        []extensionConfig       `yaml:"extensions" mapstructure:"extensions"`
+	Shortcut          shortcutConfig          `yaml:"shortcut" mapstructure:"shortcut"`
+	Kiosk             kioskConfig             `yaml:"kiosk" mapstructure:"kiosk"`
+	Golden            goldenConfig            `yaml:"golden" mapstructure:"golden"`
+	Crash             crashConfig             `yaml:"crash" mapstructure:"crash"`
 }
 
+// floorpRepository is the GitHub repository of the Floorp releases
+const floorpRepository = "Floorp-Projects/Floorp"
+
 var (
 	app *portapps.App
 	cfg *config
 )
 
-func init() {
-	var err error
-
-	// Default config
-	cfg = &config{
+// defaultConfig returns the configuration used when the config file does not
+// set a value.
+func defaultConfig() *config {
+	return &config{
 		Profile:           "default",
 		MultipleInstances: false,
 		Cleanup:           false,
+		CleanupPaths:      []string{},
+		CleanupRegistry:   []string{},
+		CleanupDryRun:     false,
+		CleanupReport:     false,
 		CheckForUpdates:   true,
+		VerifyApp:         false,
 		UpdateURL:         "https://github.com/Floorp-Projects/Floorp/releases/latest",
+		Policies:          map[string]interface{}{},
+		PolicyOverrides:   map[string]interface{}{},
+		StrictPolicies:    false,
+		StrictPortable:    false,
+		StrictDownloads:   strictDownloadsData,
+		Env:               map[string]string{},
+		EnvUnset:          []string{},
+		Args:              []string{},
+		Presets:           map[string]presetConfig{},
+		Prefs: prefsConfig{
+			Pref:        map[string]interface{}{},
+			DefaultPref: map[string]interface{}{},
+			LockPref:    map[string]interface{}{},
+			ClearPref:   []string{},
+		},
+		Search: searchConfig{
+			RepairHash: true,
+			Engines:    []searchEngineConfig{},
+		},
+		Extensions: []extensionConfig{},
+		Shortcut: shortcutConfig{
+			Mode:      shortcutModeSession,
+			Name:      "Floorp Portable",
+			Locations: []string{shortcutLocationStartMenu},
+		},
+		Kiosk: kioskConfig{
+			Reset:        true,
+			Restart:      true,
+			RestartDelay: 2,
+		},
+		Golden: goldenConfig{
+			Enabled:  false,
+			Template: "golden",
+			Persist:  []string{},
+		},
+		Crash: crashConfig{
+			CollectDumps:  false,
+			SafeModeAfter: 3,
+			KeepDumps:     10,
+		},
 	}
+}
+
+func main() {
+	var err error
 
-	// Init app
+	// Init app, not in init() so tests can run without portapp.json
+	cfg = defaultConfig()
 	if app, err = portapps.NewWithCfg("floorp-portable", "Floorp", cfg); err != nil {
 		log.Fatal().Err(err).Msg("Cannot initialize application. See log file for more info.")
 	}
-}
 
-func main() {
+	flags, args := parseLauncherFlags(os.Args[1:])
+	if flags.Profile != "" {
+		if strings.ContainsAny(flags.Profile, invalidFileNameChars) || strings.Trim(flags.Profile, ".") == "" {
+			log.Fatal().Msgf("Invalid profile name %q", flags.Profile)
+		}
+		cfg.Profile = flags.Profile
+	}
+
 	utl.CreateFolder(app.DataPath)
 	profileFolder := utl.CreateFolder(app.DataPath, "profile", cfg.Profile)
 
+	// Launcher commands
+	if flags.PrintPolicies {
+		attachConsole()
+		if err := printPolicies(); err != nil {
+			log.Fatal().Err(err).Msg("Cannot print policies")
+		}
+		return
+	}
+	if flags.ListSessions {
+		attachConsole()
+		if err := listSessions(profileFolder); err != nil {
+			log.Fatal().Err(err).Msg("Cannot list sessions")
+		}
+		return
+	}
+	if flags.ExportSession != "" {
+		attachConsole()
+		if err := exportSession(profileFolder, flags.ExportSession, flags.Format, flags.Output); err != nil {
+			log.Fatal().Err(err).Msg("Cannot export session")
+		}
+		return
+	}
+	if flags.CrashReport {
+		attachConsole()
+		output, err := writeCrashReport(flags.Output)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot write crash report")
+		}
+		fmt.Fprintf(os.Stdout, "Crash report written to %s\n", output)
+		return
+	}
+	if flags.Verify {
+		attachConsole()
+		if err := printAppIntegrity(os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("Cannot verify app folder")
+		}
+		return
+	}
+	if flags.Diagnose {
+		attachConsole()
+		output, err := writeDiagnostics(os.Stdout, profileFolder, flags.Output)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot write diagnostics report")
+		}
+		fmt.Fprintf(os.Stdout, "\nReport written to %s\n", output)
+		return
+	}
+
 	// Check for updates if enabled
 	if cfg.CheckForUpdates {
 		log.Info().Msg("Update checking is enabled")
@@ -78,7 +198,7 @@ func main() {
 
 			if confirmed {
 				log.Info().Msg("Starting update process...")
-				err := downloadAndUpdate(downloadURL)
+				err := downloadAndUpdate(downloadURL, latestVersion)
 				if err == nil {
 					log.Info().Msg("Update successful, restarting application...")
 					restartApp()
@@ -101,12 +221,29 @@ func main() {
 		profileFolder,
 	}
 
+	// Config args and presets, the command line alone is forwarded to a
+	// running instance
+	commandLine := args
+	presetArgs, presetEnv, err := launchArgs(flags.Preset, profileFolder)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot apply presets")
+	}
+	args = append(presetArgs, args...)
+	if len(presetEnv) > 0 && cfg.Env == nil {
+		cfg.Env = map[string]string{}
+	}
+	for key, value := range presetEnv {
+		cfg.Env[key] = value
+	}
+
 	// Set env vars
 	crashreporterFolder := utl.CreateFolder(app.DataPath, "crashreporter")
 	pluginsFolder := utl.CreateFolder(app.DataPath, "plugins")
-	os.Setenv("MOZ_CRASHREPORTER", "0")
+	if !cfg.Crash.CollectDumps {
+		os.Setenv("MOZ_CRASHREPORTER", "0")
+		os.Setenv("MOZ_CRASHREPORTER_DISABLE", "1")
+	}
 	os.Setenv("MOZ_CRASHREPORTER_DATA_DIRECTORY", crashreporterFolder)
-	os.Setenv("MOZ_CRASHREPORTER_DISABLE", "1")
 	os.Setenv("MOZ_CRASHREPORTER_NO_REPORT", "1")
 	os.Setenv("MOZ_DATA_REPORTING", "0")
 	os.Setenv("MOZ_MAINTENANCE_SERVICE", "0")
@@ -116,30 +253,49 @@ func main() {
 	// Create and check mutex
 	mu, err := mutex.Create(app.ID)
 	defer mutex.Release(mu)
-	if err != nil {
-		if !cfg.MultipleInstances {
-			log.Error().Msg("You have to enable multiple instances in your configuration if you want to launch another instance")
-			if _, err = win.MsgBox(
-				fmt.Sprintf("%s portable", app.Name),
-				"Other instance detected. You have to enable multiple instances in your configuration if you want to launch another instance.",
-				win.MsgBoxBtnOk|win.MsgBoxIconError); err != nil {
-				log.Error().Err(err).Msg("Cannot create dialog box")
+	otherInstance := err != nil
+	if otherInstance && !cfg.MultipleInstances {
+		// Floorp would start an unmanaged instance on another profile
+		if isRunningProfile(profileFolder) {
+			log.Info().Msg("Other instance detected, forwarding command line")
+			err := forwardToRunningInstance(profileFolder, commandLine)
+			if err == nil {
+				return
 			}
-			return
+			log.Error().Err(err).Msg("Cannot forward command line to running instance")
 		} else {
-			log.Warn().Msg("Another instance is already running")
+			log.Info().Msg("Other instance detected on another profile")
+		}
+		log.Error().Msg("You have to enable multiple instances in your configuration if you want to launch another instance")
+		if _, err = win.MsgBox(
+			fmt.Sprintf("%s portable", app.Name),
+			"Other instance detected. You have to enable multiple instances in your configuration if you want to launch another instance.",
+			win.MsgBoxBtnOk|win.MsgBoxIconError); err != nil {
+			log.Error().Err(err).Msg("Cannot create dialog box")
 		}
+		return
+	}
+	if otherInstance {
+		log.Warn().Msg("Another instance is already running")
+	} else {
+		writeRunningProfile(profileFolder)
+		defer removeRunningProfile()
+	}
+
+	// App integrity, only when Floorp is not running from this folder
+	if cfg.VerifyApp && !otherInstance {
+		quickCheckApp()
+	}
+
+	// Cleanup
+	session := &hostSession{}
+	if cfg.Cleanup || cfg.CleanupReport {
+		session.traces = snapshotHostTraces()
 	}
 
-	if cfg.Cleanup {
-		defer func() {
-			utl.Cleanup([]string{
-				path.Join(os.Getenv("APPDATA"), "Floorp"),
-				path.Join(os.Getenv("LOCALAPPDATA"), "Floorp"),
-				path.Join(os.Getenv("USERPROFILE"), "AppData", "LocalLow", "Floorp"),
-				path.Join(os.Getenv("USERPROFILE"), "AppData", "Roaming", "Floorp"),
-			})
-		}()
+	// Strict portable
+	if cfg.StrictPortable {
+		setupStrictPortable(otherInstance)
 	}
 
 	// Multiple instances
@@ -148,6 +304,31 @@ func main() {
 		app.Args = append(app.Args, "--no-remote")
 	}
 
+	// Golden profile, the kiosk mode resets the profile itself
+	if cfg.Golden.Enabled && !cfg.Kiosk.Enabled {
+		if otherInstance {
+			log.Warn().Msg("Another instance is running, the profile is not reset")
+		} else if err := resetProfile(profileFolder); err != nil {
+			log.Fatal().Err(err).Msg("Cannot reset profile")
+		}
+	}
+
+	// Kiosk
+	if cfg.Kiosk.Enabled {
+		log.Info().Msg("Kiosk mode enabled")
+		args = append(kioskArgs(), args...)
+		setupKiosk(profileFolder, otherInstance)
+	}
+
+	// Session restore
+	if flags.RestoreSession != "" {
+		if otherInstance {
+			log.Warn().Msg("Another instance is running, the session is not restored")
+		} else if err := restoreSession(profileFolder, flags.RestoreSession); err != nil {
+			log.Fatal().Err(err).Msg("Cannot restore session")
+		}
+	}
+
 	// Policies
 	if err := createPolicies(); err != nil {
 		log.Fatal().Err(err).Msg("Cannot create policies")
@@ -162,62 +343,21 @@ pref("general.config.obscure_value", 0);`); err != nil {
 		log.Fatal().Err(err).Msg("Cannot write autoconfig.js")
 	}
 
-	// Mozilla cfg
-	mozillaCfgPath := utl.PathJoin(app.AppPath, "portapps.cfg")
-	mozillaCfgFile, err := os.Create(mozillaCfgPath)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Cannot create portapps.cfg")
-	}
-	mozillaCfgTpl := template.Must(template.New("mozillaCfg").Parse(`// Extensions scopes
-lockPref("extensions.enabledScopes", 4);
-lockPref("extensions.autoDisableScopes", 3);
-
-// Don't show 'know your rights' on first run
-pref("browser.rights.3.shown", true);
-
-// Don't show WhatsNew on first run after every update
-pref("browser.startup.homepage_override.mstone", "ignore");
-`))
-	if err := mozillaCfgTpl.Execute(mozillaCfgFile, nil); err != nil {
-		log.Fatal().Err(err).Msg("Cannot write portapps.cfg")
-	}
-
-	// Fix extensions path
-	if err := updateAddonStartup(profileFolder); err != nil {
-		log.Error().Err(err).Msg("Cannot fix extensions path")
+	// Shortcuts, Floorp windows are grouped by profile as their shortcuts
+	if cfg.Shortcut.Mode != shortcutModeOff && cfg.Shortcut.PerProfile {
+		launchPrefs = append(launchPrefs, mozillaPref{Func: prefFuncPref, Name: "taskbar.grouping.useprofile", Value: "true"})
 	}
+	session.open()
 
-	// Copy default shortcut
-	shortcutPath := path.Join(os.Getenv("APPDATA"), "Microsoft", "Windows", "Start Menu", "Programs", "Floorp Portable.lnk")
-	defaultShortcut, err := assets.Asset("Floorp.lnk")
-	if err != nil {
-		log.Error().Err(err).Msg("Cannot load asset Floorp.lnk")
-	}
-	err = os.WriteFile(shortcutPath, defaultShortcut, 0644)
-	if err != nil {
-		log.Error().Err(err).Msg("Cannot write default shortcut")
+	// Mozilla cfg
+	if err := createMozillaCfg(profileFolder); err != nil {
+		log.Fatal().Err(err).Msg("Cannot create portapps.cfg")
 	}
 
-	// Update default shortcut
-	err = shortcut.Create(shortcut.Shortcut{
-		ShortcutPath:     shortcutPath,
-		TargetPath:       app.Process,
-		Arguments:        shortcut.Property{Clear: true},
-		Description:      shortcut.Property{Value: "Floorp Portable"},
-		IconLocation:     shortcut.Property{Value: app.Process},
-		WorkingDirectory: shortcut.Property{Value: app.AppPath},
-	})
-	if err != nil {
-		log.Error().Err(err).Msg("Cannot create shortcut")
-	}
-	defer func() {
-		if err := os.Remove(shortcutPath); err != nil {
-			log.Error().Err(err).Msg("Cannot remove shortcut")
-		}
-	}()
+	// Profile
+	prepareProfile(profileFolder)
 
-	defer app.Close()
-	app.Launch(os.Args[1:])
+	launchFloorp(profileFolder, args, otherInstance, session)
 }
 
 // checkForUpdates checks if a new version of Floorp is available
@@ -243,7 +383,7 @@ func checkForUpdates() (bool, string, string, string) {
 	log.Info().Msgf("Latest version from GitHub: %s", latestVersion)
 
 	// Construct download URL
-	downloadURL := strings.Replace(releaseURL, "tag", "download", 1) + "/floorp-win64.installer.exe"
+	downloadURL := releaseDownloadURL(releaseURL)
 	log.Info().Msgf("Download URL: %s", downloadURL)
 
 	// Compare versions
@@ -257,6 +397,16 @@ func checkForUpdates() (bool, string, string, string) {
 	return false, "", currentVersion, latestVersion
 }
 
+// releaseDownloadURL returns the installer URL of a GitHub release page
+func releaseDownloadURL(releaseURL string) string {
+	return strings.Replace(releaseURL, "tag", "download", 1) + "/floorp-win64.installer.exe"
+}
+
+// releasePageURL returns the GitHub release page of a version
+func releasePageURL(version string) string {
+	return fmt.Sprintf("https://github.com/%s/releases/tag/v%s", floorpRepository, version)
+}
+
 // getPortappVersion reads the current version from portapp.json
 func getPortappVersion() (string, error) {
 	// Get the directory of the executable
@@ -299,7 +449,7 @@ func getPortappVersion() (string, error) {
 // getLatestGitHubRelease gets the latest version from GitHub releases
 func getLatestGitHubRelease() (string, string, error) {
 	// GitHub API URL for latest release
-	apiURL := "https://api.github.com/repos/Floorp-Projects/Floorp/releases/latest"
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", floorpRepository)
 
 	// Create a client with timeout
 	client := &http.Client{
@@ -466,7 +616,7 @@ func showUpdateProgress(message string, percent int) {
 }
 
 // downloadAndUpdate downloads and installs the update
-func downloadAndUpdate(downloadURL string) error {
+func downloadAndUpdate(downloadURL string, version string) error {
 	// Show update starting dialog
 	showUpdateProgress("Starting update process...", 0)
 	
@@ -510,11 +660,11 @@ func downloadAndUpdate(downloadURL string) error {
 	
 	// Extract and update
 	log.Info().Msg("Installing update...")
-	return extractAndUpdate(zipPath)
+	return extractAndUpdate(zipPath, version)
 }
 
 // extractAndUpdate extracts the zip file and updates the application
-func extractAndUpdate(zipPath string) error {
+func extractAndUpdate(zipPath string, version string) error {
 	// Create a temporary directory for extraction
 	extractDir, err := os.MkdirTemp("", "floorp-extract")
 	if err != nil {
@@ -536,6 +686,12 @@ func extractAndUpdate(zipPath string) error {
 	}
 	
 	log.Info().Msg("Found app directory: " + appDir)
+
+	// Hash the extracted files to check the copy and later launches
+	manifest, err := buildAppManifest(appDir, version)
+	if err != nil {
+		return err
+	}
 	
 	// Show update progress dialog
 	showUpdateProgress("Updating files...", 75)
@@ -561,11 +717,22 @@ func extractAndUpdate(zipPath string) error {
 		return err
 	}
 	
+	// Check the copy against the extracted files
+	if result := checkAppIntegrity(manifest, app.AppPath, true); !result.ok() {
+		log.Error().Msgf("Copied app folder does not match the update:\n%s", result)
+		os.RemoveAll(app.AppPath)
+		os.Rename(backupPath, app.AppPath)
+		return errors.New("copied files do not match the update")
+	}
+	if err := writeAppManifest(manifest); err != nil {
+		log.Warn().Err(err).Msg("Failed to write app manifest")
+	}
+
 	// Remove backup after successful update
 	os.RemoveAll(backupPath)
 	
 	// Update portapp.json with new version information
-	if err := updatePortappJson(); err != nil {
+	if err := updatePortappJson(version); err != nil {
 		log.Warn().Err(err).Msg("Failed to update portapp.json version")
 		// Continue even if this fails - it's not critical
 	}
@@ -619,8 +786,8 @@ func downloadFile(url string, filepath string) error {
 	return nil
 }
 
-// updatePortappJson updates the version in portapp.json to the latest version
-func updatePortappJson() error {
+// updatePortappJson updates the version in portapp.json to the installed version
+func updatePortappJson(version string) error {
 	// Get the directory of the executable
 	execPath, err := os.Executable()
 	if err != nil {
@@ -647,14 +814,8 @@ func updatePortappJson() error {
 		return errors.Wrap(err, "failed to parse portapp.json")
 	}
 
-	// Get latest version from GitHub
-	latestVersion, _, err := getLatestGitHubRelease()
-	if err != nil {
-		return errors.Wrap(err, "failed to get latest version")
-	}
-
 	// Update version in the JSON
-	portappData["version"] = latestVersion
+	portappData["version"] = version
 
 	// Convert back to JSON with pretty-printing
 	updatedJson, err := json.MarshalIndent(portappData, "", "  ")
@@ -667,7 +828,7 @@ func updatePortappJson() error {
 		return errors.Wrap(err, "failed to write updated portapp.json")
 	}
 
-	log.Info().Msgf("Updated portapp.json version to %s", latestVersion)
+	log.Info().Msgf("Updated portapp.json version to %s", version)
 	return nil
 }
 
@@ -922,73 +1083,3 @@ func restartApp() {
 	// Exit current process
 	os.Exit(0)
 }
-
-func createPolicies() error {
-	appFile := utl.PathJoin(utl.CreateFolder(app.AppPath, "distribution"), "policies.json")
-	dataFile := utl.PathJoin(app.DataPath, "policies.json")
-	defaultPolicies := struct {
-		Policies map[string]interface{} `json:"policies"`
-	}{
-		Policies: map[string]interface{}{
-			"DisableAppUpdate":        true,
-			"DontCheckDefaultBrowser": true,
-		},
-	}
-
-	jsonPolicies, err := gabs.Consume(defaultPolicies)
-	if err != nil {
-		return errors.Wrap(err, "Cannot consume default policies")
-	}
-	log.Debug().Msgf("Default policies: %s", jsonPolicies.String())
-
-	if utl.Exists(dataFile) {
-		rawCustomPolicies, err := os.ReadFile(dataFile)
-		if err != nil {
-			return errors.Wrap(err, "Cannot read custom policies")
-		}
-
-		jsonPolicies, err = gabs.ParseJSON(rawCustomPolicies)
-		if err != nil {
-			return errors.Wrap(err, "Cannot consume custom policies")
-		}
-		log.Debug().Msgf("Custom policies: %s", jsonPolicies.String())
-
-		jsonPolicies.Set(true, "policies", "DisableAppUpdate")
-		jsonPolicies.Set(true, "policies", "DontCheckDefaultBrowser")
-	}
-
-	log.Debug().Msgf("Applied policies: %s", jsonPolicies.String())
-	err = os.WriteFile(appFile, []byte(jsonPolicies.StringIndent("", "  ")), 0644)
-	if err != nil {
-		return errors.Wrap(err, "Cannot write policies")
-	}
-
-	return nil
-}
-
-func updateAddonStartup(profileFolder string) error {
-	lz4File := path.Join(profileFolder, "addonStartup.json.lz4")
-	if !utl.Exists(lz4File) || app.Prev.RootPath == "" {
-		return nil
-	}
-
-	lz4Raw, err := mozLz4Decompress(lz4File)
-	if err != nil {
-		return err
-	}
-
-	prevPathLin := strings.Replace(utl.FormatUnixPath(app.Prev.RootPath), ` `, `%20`, -1)
-	currPathLin := strings.Replace(utl.FormatUnixPath(app.RootPath), ` `, `%20`, -1)
-	lz4Str := strings.Replace(string(lz4Raw), prevPathLin, currPathLin, -1)
-
-	prevPathWin := strings.Replace(strings.Replace(utl.FormatWindowsPath(app.Prev.RootPath), `\`, `\\`, -1), ` `, `%20`, -1)
-	currPathWin := strings.Replace(strings.Replace(utl.FormatWindowsPath(app.RootPath), `\`, `\\`, -1), ` `, `%20`, -1)
-	lz4Str = strings.Replace(lz4Str, prevPathWin, currPathWin, -1)
-
-	lz4Enc, err := mozLz4Compress([]byte(lz4Str))
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(lz4File, lz4Enc, 0644)
-}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/portapps/portapps/v3"
+)
+
+// TestMain sets up the default configuration and an app without paths, which
+// the tests point to temporary folders.
+func TestMain(m *testing.M) {
+	cfg = defaultConfig()
+	app = &portapps.App{ID: "floorp-portable", Name: "Floorp"}
+	os.Exit(m.Run())
+}
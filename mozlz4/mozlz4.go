@@ -0,0 +1,265 @@
+// Package mozlz4 reads and writes the mozLz4 format used by Mozilla browsers
+// for files such as sessionstore.jsonlz4, search.json.mozlz4 or
+// addonStartup.json.lz4: a magic header, the little-endian uint32 size of the
+// decompressed data and a single LZ4 block.
+package mozlz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pierrec/lz4/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Magic is the header of mozLz4 files.
+	Magic = "mozLz40\x00"
+
+	// DefaultMaxSize is the largest decompressed size accepted by default.
+	DefaultMaxSize = 256 << 20
+
+	headerSize = len(Magic) + 4
+)
+
+var (
+	// ErrNoHeader is returned when the data does not start with the mozLz4 header.
+	ErrNoHeader = errors.New("no mozLz4 header")
+	// ErrTooLarge is returned when the decompressed size exceeds the maximum size.
+	ErrTooLarge = errors.New("mozLz4 data too large")
+)
+
+// Reader decompresses mozLz4 data read from an underlying reader. The whole
+// LZ4 block is decompressed on the first read, the format not allowing more.
+type Reader struct {
+	r       io.Reader
+	maxSize int
+	buf     *bytes.Reader
+	err     error
+}
+
+// NewReader returns a reader decompressing r, accepting up to DefaultMaxSize
+// bytes of decompressed data.
+func NewReader(r io.Reader) *Reader {
+	return NewReaderSize(r, DefaultMaxSize)
+}
+
+// NewReaderSize returns a reader decompressing r, accepting up to maxSize
+// bytes of decompressed data.
+func NewReaderSize(r io.Reader, maxSize int) *Reader {
+	return &Reader{r: r, maxSize: maxSize}
+}
+
+// Size reads the header and returns the size of the decompressed data.
+func (z *Reader) Size() (int, error) {
+	if err := z.init(); err != nil {
+		return 0, err
+	}
+	return int(z.buf.Size()), nil
+}
+
+// Read implements io.Reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	if err := z.init(); err != nil {
+		return 0, err
+	}
+	return z.buf.Read(p)
+}
+
+// WriteTo implements io.WriterTo.
+func (z *Reader) WriteTo(w io.Writer) (int64, error) {
+	if err := z.init(); err != nil {
+		return 0, err
+	}
+	return z.buf.WriteTo(w)
+}
+
+func (z *Reader) init() error {
+	if z.buf != nil || z.err != nil {
+		return z.err
+	}
+	var out []byte
+	out, z.err = z.decompress()
+	if z.err == nil {
+		z.buf = bytes.NewReader(out)
+	}
+	return z.err
+}
+
+func (z *Reader) decompress() ([]byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(z.r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNoHeader
+		}
+		return nil, errors.Wrap(err, "couldn't read header")
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return nil, ErrNoHeader
+	}
+
+	size := binary.LittleEndian.Uint32(header[len(Magic):])
+	if uint64(size) > uint64(z.maxSize) {
+		return nil, errors.Wrapf(ErrTooLarge, "%d bytes exceeds %d", size, z.maxSize)
+	}
+
+	// A valid block is never larger than the bound of its decompressed size
+	bound := int64(lz4.CompressBlockBound(int(size)))
+	src, err := io.ReadAll(io.LimitReader(z.r, bound+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read compressed data")
+	}
+	if int64(len(src)) > bound {
+		return nil, errors.New("compressed data larger than expected")
+	}
+	// A block never expands more than 255 times, so a forged size is rejected
+	// before allocating it
+	if uint64(size) > uint64(len(src))*255+16 {
+		return nil, errors.Errorf("decompressed size %d too large for %d compressed bytes", size, len(src))
+	}
+
+	out := make([]byte, size)
+	if size == 0 {
+		return out, nil
+	}
+	n, err := lz4.UncompressBlock(src, out)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't decompress data")
+	}
+	if n != int(size) {
+		return nil, errors.Errorf("decompressed %d bytes, expected %d", n, size)
+	}
+
+	return out, nil
+}
+
+// Writer compresses the data written to it into mozLz4 format. Data is
+// buffered and written to the underlying writer on Close.
+type Writer struct {
+	w       io.Writer
+	maxSize int
+	buf     bytes.Buffer
+	closed  bool
+}
+
+// NewWriter returns a writer compressing to w, accepting up to
+// DefaultMaxSize bytes.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterSize(w, DefaultMaxSize)
+}
+
+// NewWriterSize returns a writer compressing to w, accepting up to maxSize
+// bytes.
+func NewWriterSize(w io.Writer, maxSize int) *Writer {
+	return &Writer{w: w, maxSize: maxSize}
+}
+
+// Write implements io.Writer.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("write to closed mozLz4 writer")
+	}
+	if z.buf.Len()+len(p) > z.maxSize {
+		return 0, ErrTooLarge
+	}
+	return z.buf.Write(p)
+}
+
+// Close compresses the buffered data and writes it to the underlying writer
+// in a single write, so nothing is written if the compression fails. It does
+// not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+
+	src := z.buf.Bytes()
+	if uint64(len(src)) > math.MaxUint32 {
+		return errors.Wrapf(ErrTooLarge, "%d bytes exceeds the 4 GiB limit of the format", len(src))
+	}
+	block, err := compressBlock(src)
+	if err != nil {
+		return err
+	}
+
+	out := make([]byte, headerSize, headerSize+len(block))
+	copy(out, Magic)
+	binary.LittleEndian.PutUint32(out[len(Magic):], uint32(len(src)))
+	if _, err := z.w.Write(append(out, block...)); err != nil {
+		return errors.Wrap(err, "couldn't write compressed data")
+	}
+
+	return nil
+}
+
+// compressBlock compresses src into a single LZ4 block. Incompressible data
+// is stored as a block of literals.
+func compressBlock(src []byte) ([]byte, error) {
+	dst := make([]byte, lz4.CompressBlockBound(len(src)))
+	n, err := lz4.CompressBlockHC(src, dst, -1)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't compress data")
+	}
+	if n == 0 {
+		return literalBlock(src), nil
+	}
+	return dst[:n], nil
+}
+
+// literalBlock returns an LZ4 block made of a single literal-only sequence.
+func literalBlock(src []byte) []byte {
+	block := make([]byte, 0, lz4.CompressBlockBound(len(src)))
+	if len(src) < 15 {
+		block = append(block, byte(len(src)<<4))
+	} else {
+		block = append(block, 0xF0)
+		rest := len(src) - 15
+		for ; rest >= 255; rest -= 255 {
+			block = append(block, 255)
+		}
+		block = append(block, byte(rest))
+	}
+	return append(block, src...)
+}
+
+// Decompress decompresses mozLz4 data.
+func Decompress(data []byte) ([]byte, error) {
+	return io.ReadAll(NewReader(bytes.NewReader(data)))
+}
+
+// Compress compresses data to mozLz4 format.
+func Compress(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	z := NewWriter(&out)
+	if _, err := z.Write(data); err != nil {
+		return nil, err
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// ReadFile reads and decompresses the named mozLz4 file.
+func ReadFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(NewReader(file))
+}
+
+// WriteFile compresses data and writes it to the named file.
+func WriteFile(filename string, data []byte, perm os.FileMode) error {
+	compressed, err := Compress(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, compressed, perm)
+}
@@ -0,0 +1,211 @@
+package mozlz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/pierrec/lz4/v3"
+)
+
+// header returns a mozLz4 header announcing size decompressed bytes.
+func header(size uint32) []byte {
+	h := make([]byte, headerSize)
+	copy(h, Magic)
+	binary.LittleEndian.PutUint32(h[len(Magic):], size)
+	return h
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(b)
+	return b
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"one byte", []byte("{")},
+		{"json", []byte(`{"version":["sessionrestore",1],"windows":[{"tabs":[]}],"session":{"state":"stopped"}}`)},
+		{"repetitive", bytes.Repeat([]byte("floorp "), 10000)},
+		{"incompressible", randomBytes(64 << 10)},
+		{"incompressible literals 15", randomBytes(15)},
+		{"incompressible literals 270", randomBytes(270)},
+		{"incompressible literals 255+15+1", randomBytes(255 + 15 + 1)},
+		{"incompressible literals 255+255+15", randomBytes(255 + 255 + 15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, err := Compress(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(compressed, header(uint32(len(tt.data)))) {
+				t.Fatalf("header = %x, want %x", compressed[:headerSize], header(uint32(len(tt.data))))
+			}
+			got, err := Decompress(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("round trip changed %d bytes into %d bytes", len(tt.data), len(got))
+			}
+		})
+	}
+}
+
+func TestLiteralBlock(t *testing.T) {
+	for _, n := range []int{0, 1, 14, 15, 16, 269, 270, 271, 255 + 15, 255 + 255 + 15, 1000} {
+		src := randomBytes(n)
+		block := literalBlock(src)
+
+		// The length is stored in the token up to 14, then in extra bytes
+		wantOverhead := 1
+		if n >= 15 {
+			wantOverhead = 2 + (n-15)/255
+		}
+		if overhead := len(block) - n; overhead != wantOverhead {
+			t.Errorf("literalBlock(%d bytes) overhead = %d, want %d", n, overhead, wantOverhead)
+		}
+
+		out := make([]byte, n)
+		got, err := lz4.UncompressBlock(block, out)
+		if n == 0 {
+			continue
+		}
+		if err != nil {
+			t.Errorf("literalBlock(%d bytes): %v", n, err)
+			continue
+		}
+		if got != n || !bytes.Equal(out, src) {
+			t.Errorf("literalBlock(%d bytes) decompressed to %d different bytes", n, got)
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	valid, err := Compress([]byte(`{"engines":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int
+		wantErr error
+	}{
+		{name: "empty", data: nil, wantErr: ErrNoHeader},
+		{name: "truncated header", data: []byte(Magic), wantErr: ErrNoHeader},
+		{name: "bad magic", data: append([]byte("mozLz41\x00"), 0, 0, 0, 0), wantErr: ErrNoHeader},
+		{name: "too large", data: header(DefaultMaxSize + 1), wantErr: ErrTooLarge},
+		{name: "larger than max size", data: valid, maxSize: 4, wantErr: ErrTooLarge},
+		{name: "forged size", data: header(DefaultMaxSize)},
+		{name: "forged size with data", data: append(header(1<<20), 0x10, 'a')},
+		{name: "truncated block", data: valid[:len(valid)-2]},
+		{name: "trailing data", data: append(append([]byte{}, valid...), bytes.Repeat([]byte{0}, 64)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = DefaultMaxSize
+			}
+			_, err := NewReaderSize(bytes.NewReader(tt.data), maxSize).Size()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriterMaxSize(t *testing.T) {
+	var out bytes.Buffer
+	z := NewWriterSize(&out, 4)
+	if _, err := z.Write([]byte("abcde")); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Write() error = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+// writeRecorder records the writes made to it.
+type writeRecorder struct {
+	writes [][]byte
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestWriterSingleWrite(t *testing.T) {
+	data := []byte(`{"windows":[],"windows":[],"windows":[]}`)
+	var w writeRecorder
+	z := NewWriter(&w)
+	if _, err := z.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 0 {
+		t.Fatalf("Write() wrote %d times before Close", len(w.writes))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 1 {
+		t.Fatalf("Close() wrote %d times, want the header and block at once", len(w.writes))
+	}
+	if !bytes.Equal(w.writes[0][:headerSize], header(uint32(len(data)))) {
+		t.Errorf("Close() header = % X, want % X", w.writes[0][:headerSize], header(uint32(len(data))))
+	}
+	if out, err := Decompress(w.writes[0]); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("Decompress() = %q, %v, want %q", out, err, data)
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	for _, seed := range []string{
+		`{"version":["sessionrestore",1],"windows":[],"session":{"lastUpdate":1700000000000}}`,
+		`{"version":1,"engines":[{"_name":"DuckDuckGo","_isAppProvided":true}],"metaData":{"useSavedOrder":false}}`,
+		`{"app-system-defaults":{"addons":{}},"app-profile":{"addons":{}}}`,
+		"",
+	} {
+		compressed, err := Compress([]byte(seed))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(compressed)
+	}
+	f.Add(header(0))
+	f.Add(header(DefaultMaxSize))
+	f.Add(append(header(15), 0xF0, 0x00, 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o'))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := Decompress(data)
+		if err != nil {
+			return
+		}
+		size := binary.LittleEndian.Uint32(data[len(Magic):headerSize])
+		if len(out) != int(size) {
+			t.Fatalf("decompressed %d bytes, header says %d", len(out), size)
+		}
+
+		compressed, err := Compress(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := Decompress(compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, out) {
+			t.Fatal("round trip of decompressed data differs")
+		}
+	})
+}
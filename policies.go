@@ -0,0 +1,518 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// Policy layers, from lowest to highest precedence. A higher layer wins over a
+// lower one following these rules:
+//   - objects are merged key by key, recursively
+//   - scalars replace the lower value
+//   - arrays are appended, skipping values already present (the overrides
+//     layer replaces arrays instead)
+//   - null removes the key set by lower layers
+const (
+	policyLayerDefault      = "default"
+	policyLayerOrganisation = "organisation"
+	policyLayerProfile      = "profile"
+	policyLayerSearch       = "search"
+	policyLayerConfig       = "config"
+	policyLayerKiosk        = "kiosk"
+	policyLayerOverrides    = "overrides"
+	policyLayerEnforced     = "enforced"
+)
+
+// enforcedPolicies are always applied on top of every layer.
+var enforcedPolicies = map[string]interface{}{
+	"DisableAppUpdate":        true,
+	"DontCheckDefaultBrowser": true,
+}
+
+// policyLayer is one source of policies.
+type policyLayer struct {
+	Name          string
+	Source        string
+	Policies      map[string]interface{}
+	ReplaceArrays bool
+}
+
+// policySet is the result of merging policy layers. Origins maps each key path
+// (e.g. policies.Homepage.URL) to the layers that set it.
+type policySet struct {
+	Policies map[string]interface{}
+	Origins  map[string]string
+}
+
+// loadPolicyLayers returns the policy layers in order of precedence.
+func loadPolicyLayers() ([]policyLayer, error) {
+	layers := []policyLayer{{
+		Name:     policyLayerDefault,
+		Source:   "built-in",
+		Policies: copyPolicies(enforcedPolicies),
+	}}
+
+	files := []struct {
+		name string
+		path string
+	}{
+		{policyLayerOrganisation, utl.PathJoin(app.DataPath, "policies.json")},
+		{policyLayerProfile, utl.PathJoin(app.DataPath, "policies", fmt.Sprintf("%s.json", cfg.Profile))},
+	}
+	for _, file := range files {
+		if !utl.Exists(file.path) {
+			continue
+		}
+		policies, err := readPoliciesFile(file.path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot load %s policies", file.name)
+		}
+		layers = append(layers, policyLayer{
+			Name:     file.name,
+			Source:   file.path,
+			Policies: policies,
+		})
+	}
+
+	if policies := searchPolicies(cfg.Search); policies != nil {
+		layers = append(layers, policyLayer{
+			Name:     policyLayerSearch,
+			Source:   "config (search)",
+			Policies: policies,
+		})
+	}
+
+	if len(cfg.Policies) > 0 {
+		policies, err := normalizePolicyValue(cfg.Policies)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot load policies from config")
+		}
+		layers = append(layers, policyLayer{
+			Name:     policyLayerConfig,
+			Source:   "config",
+			Policies: policies.(map[string]interface{}),
+		})
+	}
+
+	if cfg.Kiosk.Enabled {
+		layers = append(layers, policyLayer{
+			Name:     policyLayerKiosk,
+			Source:   "config (kiosk)",
+			Policies: kioskPolicies(cfg.Kiosk),
+		})
+	}
+
+	if len(cfg.PolicyOverrides) > 0 {
+		overrides, err := expandPolicyOverrides(cfg.PolicyOverrides)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot load policy overrides")
+		}
+		layers = append(layers, policyLayer{
+			Name:          policyLayerOverrides,
+			Source:        "config (policy_overrides)",
+			Policies:      overrides,
+			ReplaceArrays: true,
+		})
+	}
+
+	return append(layers, policyLayer{
+		Name:     policyLayerEnforced,
+		Source:   "built-in",
+		Policies: copyPolicies(enforcedPolicies),
+	}), nil
+}
+
+// readPoliciesFile reads the policies object of a policies.json file.
+func readPoliciesFile(filename string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.Wrapf(err, "Cannot parse %s", filename)
+	}
+
+	policies, ok := doc["policies"].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("No policies object found in %s", filename)
+	}
+
+	return policies, nil
+}
+
+// expandPolicyOverrides turns dotted keys (e.g. Homepage.URL) into nested
+// policy objects. Segments containing dots are quoted or escaped, e.g.
+// Preferences."browser.startup.homepage".Value or
+// ExtensionSettings.uBlock0@raymondhill\.net.installation_mode.
+func expandPolicyOverrides(overrides map[string]interface{}) (map[string]interface{}, error) {
+	normalized, err := normalizePolicyValue(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	type override struct {
+		parts []string
+		value interface{}
+	}
+	var sorted []override
+	for key, value := range normalized.(map[string]interface{}) {
+		parts, err := splitPolicyKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid policy override %q", key)
+		}
+		sorted = append(sorted, override{parts: parts, value: value})
+	}
+
+	// Shorter paths first, so Homepage.URL is applied on top of Homepage
+	// whatever the order of the map
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].parts) != len(sorted[j].parts) {
+			return len(sorted[i].parts) < len(sorted[j].parts)
+		}
+		return strings.Join(sorted[i].parts, "\x00") < strings.Join(sorted[j].parts, "\x00")
+	})
+
+	expanded := map[string]interface{}{}
+	for _, o := range sorted {
+		parts, value := o.parts, o.value
+		node := expanded
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+
+	return expanded, nil
+}
+
+// splitPolicyKey splits a dotted policy key into its segments. A dot inside
+// double quotes or preceded by a backslash is part of the segment.
+func splitPolicyKey(key string) ([]string, error) {
+	var parts []string
+	var part strings.Builder
+	quoted, escaped := false, false
+	for _, r := range key {
+		switch {
+		case escaped:
+			part.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	parts = append(parts, part.String())
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, errors.New("empty segment")
+		}
+	}
+	return parts, nil
+}
+
+// normalizePolicyValue converts a value decoded from the YAML configuration
+// into its JSON representation.
+func normalizePolicyValue(value interface{}) (interface{}, error) {
+	raw, err := json.Marshal(stringifyYamlKeys(value))
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// stringifyYamlKeys converts YAML mappings with non-string keys (e.g. numbers
+// or booleans), which cannot be encoded to JSON, into maps with string keys.
+func stringifyYamlKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			converted[fmt.Sprintf("%v", key)] = stringifyYamlKeys(child)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			converted[key] = stringifyYamlKeys(child)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, child := range v {
+			converted[i] = stringifyYamlKeys(child)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+// mergePolicyLayers merges layers in order and records where each key comes from.
+func mergePolicyLayers(layers []policyLayer) *policySet {
+	set := &policySet{
+		Policies: map[string]interface{}{},
+		Origins:  map[string]string{},
+	}
+	for _, layer := range layers {
+		log.Debug().Msgf("Merging %s policies from %s", layer.Name, layer.Source)
+		set.merge(set.Policies, layer.Policies, "policies", layer)
+	}
+	return set
+}
+
+func (set *policySet) merge(dst map[string]interface{}, src map[string]interface{}, path string, layer policyLayer) {
+	for key, value := range src {
+		keyPath := path + "." + key
+
+		switch value := value.(type) {
+		case nil:
+			delete(dst, key)
+			set.forget(keyPath)
+		case map[string]interface{}:
+			current, ok := dst[key].(map[string]interface{})
+			if !ok {
+				current = map[string]interface{}{}
+				dst[key] = current
+				set.forget(keyPath)
+			}
+			set.merge(current, value, keyPath, layer)
+		case []interface{}:
+			current, ok := dst[key].([]interface{})
+			if ok && !layer.ReplaceArrays {
+				dst[key] = appendUniqueValues(current, value)
+				set.Origins[keyPath] += ", " + layer.Name
+			} else {
+				dst[key] = copyPolicyValue(value)
+				set.forget(keyPath)
+				set.Origins[keyPath] = layer.Name
+			}
+		default:
+			dst[key] = value
+			set.forget(keyPath)
+			set.Origins[keyPath] = layer.Name
+		}
+	}
+}
+
+// forget removes the origins of a key path and its children.
+func (set *policySet) forget(keyPath string) {
+	for origin := range set.Origins {
+		if origin == keyPath || strings.HasPrefix(origin, keyPath+".") {
+			delete(set.Origins, origin)
+		}
+	}
+}
+
+// JSON returns the policies.json document of the set.
+func (set *policySet) JSON() (*gabs.Container, error) {
+	return gabs.Consume(map[string]interface{}{
+		"policies": set.Policies,
+	})
+}
+
+// Print writes the effective policies and the layer that set each key.
+func (set *policySet) Print(w io.Writer, layers []policyLayer) error {
+	jsonPolicies, err := set.JSON()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Layers:")
+	for _, layer := range layers {
+		fmt.Fprintf(w, "  %-14s %s\n", layer.Name, layer.Source)
+	}
+
+	fmt.Fprintln(w, "\nEffective policies:")
+	fmt.Fprintln(w, jsonPolicies.StringIndent("", "  "))
+
+	keyPaths := make([]string, 0, len(set.Origins))
+	width := 0
+	for keyPath := range set.Origins {
+		keyPaths = append(keyPaths, keyPath)
+		if len(keyPath) > width {
+			width = len(keyPath)
+		}
+	}
+	sort.Strings(keyPaths)
+
+	fmt.Fprintln(w, "\nOrigins:")
+	for _, keyPath := range keyPaths {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, keyPath, set.Origins[keyPath])
+	}
+
+	return nil
+}
+
+func appendUniqueValues(dst []interface{}, src []interface{}) []interface{} {
+	merged := append([]interface{}{}, dst...)
+	for _, value := range src {
+		found := false
+		for _, existing := range merged {
+			if reflect.DeepEqual(existing, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, copyPolicyValue(value))
+		}
+	}
+	return merged
+}
+
+func copyPolicies(policies map[string]interface{}) map[string]interface{} {
+	return copyPolicyValue(policies).(map[string]interface{})
+}
+
+// copyPolicyValue returns a deep copy of a policy value, so the merged
+// policies never share objects or arrays with a layer.
+func copyPolicyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			copied[key] = copyPolicyValue(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, child := range v {
+			copied[i] = copyPolicyValue(child)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// effectivePolicies loads and merges every policy layer.
+func effectivePolicies() (*policySet, []policyLayer, error) {
+	layers, err := loadPolicyLayers()
+	if err != nil {
+		return nil, nil, err
+	}
+	return mergePolicyLayers(layers), layers, nil
+}
+
+// printPolicies writes the effective policies and their validation result to stdout.
+func printPolicies() error {
+	set, layers, err := effectivePolicies()
+	if err != nil {
+		return err
+	}
+	if err := set.Print(os.Stdout, layers); err != nil {
+		return err
+	}
+
+	schema, schemaSource, err := loadPolicySchema()
+	if err != nil {
+		return err
+	}
+	errs := validatePolicies(schema, set.Policies)
+
+	fmt.Fprintf(os.Stdout, "\nValidation (schema %s):\n", schemaSource)
+	if len(errs) == 0 {
+		fmt.Fprintln(os.Stdout, "  no errors")
+	}
+	for _, e := range errs {
+		if e.fatal(schemaSource) {
+			fmt.Fprintf(os.Stdout, "  %s\n", e)
+		} else {
+			fmt.Fprintf(os.Stdout, "  %s (warning)\n", e)
+		}
+	}
+
+	return nil
+}
+
+// checkPolicies validates policies against the schema. Errors are logged and
+// only prevent the launch in strict mode. Policy names unknown to the built-in
+// schema are never fatal.
+func checkPolicies(set *policySet) error {
+	schema, schemaSource, err := loadPolicySchema()
+	if err != nil {
+		return err
+	}
+
+	errs := validatePolicies(schema, set.Policies)
+	if len(errs) == 0 {
+		log.Debug().Msgf("Policies are valid against %s schema", schemaSource)
+		return nil
+	}
+
+	var messages []string
+	for _, e := range errs {
+		if !e.fatal(schemaSource) {
+			log.Warn().Msgf("Policy %s, it may be missing from the built-in schema", e)
+			continue
+		}
+		log.Warn().Msgf("Invalid policy %s", e)
+		messages = append(messages, e.String())
+	}
+	if cfg.StrictPolicies && len(messages) > 0 {
+		return errors.Errorf("%d policy error(s) found against %s schema:\n%s", len(messages), schemaSource, strings.Join(messages, "\n"))
+	}
+
+	return nil
+}
+
+func createPolicies() error {
+	appFile := utl.PathJoin(utl.CreateFolder(app.AppPath, "distribution"), "policies.json")
+
+	set, _, err := effectivePolicies()
+	if err != nil {
+		return err
+	}
+	if err := checkPolicies(set); err != nil {
+		return err
+	}
+
+	jsonPolicies, err := set.JSON()
+	if err != nil {
+		return errors.Wrap(err, "Cannot consume policies")
+	}
+
+	log.Debug().Msgf("Applied policies: %s", jsonPolicies.String())
+	err = os.WriteFile(appFile, []byte(jsonPolicies.StringIndent("", "  ")), 0644)
+	if err != nil {
+		return errors.Wrap(err, "Cannot write policies")
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPolicyKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{key: "DisableTelemetry", want: []string{"DisableTelemetry"}},
+		{key: "Homepage.URL", want: []string{"Homepage", "URL"}},
+		{key: `Preferences."browser.startup.homepage".Value`, want: []string{"Preferences", "browser.startup.homepage", "Value"}},
+		{key: `ExtensionSettings.uBlock0@raymondhill\.net.installation_mode`, want: []string{"ExtensionSettings", "uBlock0@raymondhill.net", "installation_mode"}},
+		{key: `ExtensionSettings."uBlock0@raymondhill.net"`, want: []string{"ExtensionSettings", "uBlock0@raymondhill.net"}},
+		{key: `Weird.a\"b`, want: []string{"Weird", `a"b`}},
+		{key: `Preferences."browser.startup.homepage`, wantErr: true},
+		{key: `Homepage.URL\`, wantErr: true},
+		{key: "Homepage..URL", wantErr: true},
+		{key: "Homepage.", wantErr: true},
+		{key: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitPolicyKey(tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitPolicyKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPolicyKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPolicyOverrides(t *testing.T) {
+	got, err := expandPolicyOverrides(map[string]interface{}{
+		"Homepage.StartPage":                                           "homepage",
+		`Preferences."browser.startup.homepage".Value`:                 "https://floorp.app/",
+		`ExtensionSettings.uBlock0@raymondhill\.net.installation_mode`: "force_installed",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"Homepage": map[string]interface{}{"StartPage": "homepage"},
+		"Preferences": map[string]interface{}{
+			"browser.startup.homepage": map[string]interface{}{"Value": "https://floorp.app/"},
+		},
+		"ExtensionSettings": map[string]interface{}{
+			"uBlock0@raymondhill.net": map[string]interface{}{"installation_mode": "force_installed"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandPolicyOverrides() = %v, want %v", got, want)
+	}
+
+	if _, err := expandPolicyOverrides(map[string]interface{}{`Preferences."broken`: true}); err == nil {
+		t.Error("expandPolicyOverrides() accepted an unterminated quote")
+	}
+}
+
+func TestExpandPolicyOverridesOrder(t *testing.T) {
+	// Map iteration order is random, repeat to catch order dependencies
+	for i := 0; i < 20; i++ {
+		got, err := expandPolicyOverrides(map[string]interface{}{
+			"Homepage.URL": "https://floorp.app/",
+			"Homepage": map[string]interface{}{
+				"URL":    "https://example.com/",
+				"Locked": true,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]interface{}{
+			"Homepage": map[string]interface{}{"URL": "https://floorp.app/", "Locked": true},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expandPolicyOverrides() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMergePolicyLayers(t *testing.T) {
+	tests := []struct {
+		name        string
+		layers      []policyLayer
+		want        map[string]interface{}
+		wantOrigins map[string]string
+	}{
+		{
+			name: "higher layer wins",
+			layers: []policyLayer{
+				{Name: "low", Policies: map[string]interface{}{
+					"DisableTelemetry": false,
+					"Homepage":         map[string]interface{}{"URL": "https://low.example/", "Locked": true},
+				}},
+				{Name: "high", Policies: map[string]interface{}{
+					"DisableTelemetry": true,
+					"Homepage":         map[string]interface{}{"URL": "https://high.example/"},
+				}},
+			},
+			want: map[string]interface{}{
+				"DisableTelemetry": true,
+				"Homepage":         map[string]interface{}{"URL": "https://high.example/", "Locked": true},
+			},
+			wantOrigins: map[string]string{
+				"policies.DisableTelemetry": "high",
+				"policies.Homepage.URL":     "high",
+				"policies.Homepage.Locked":  "low",
+			},
+		},
+		{
+			name: "arrays are appended without duplicates",
+			layers: []policyLayer{
+				{Name: "low", Policies: map[string]interface{}{
+					"WebsiteFilter": map[string]interface{}{"Block": []interface{}{"a", "b"}},
+				}},
+				{Name: "high", Policies: map[string]interface{}{
+					"WebsiteFilter": map[string]interface{}{"Block": []interface{}{"b", "c"}},
+				}},
+			},
+			want: map[string]interface{}{
+				"WebsiteFilter": map[string]interface{}{"Block": []interface{}{"a", "b", "c"}},
+			},
+			wantOrigins: map[string]string{
+				"policies.WebsiteFilter.Block": "low, high",
+			},
+		},
+		{
+			name: "overrides replace arrays",
+			layers: []policyLayer{
+				{Name: "low", Policies: map[string]interface{}{
+					"WebsiteFilter": map[string]interface{}{"Block": []interface{}{"a", "b"}},
+				}},
+				{Name: "overrides", ReplaceArrays: true, Policies: map[string]interface{}{
+					"WebsiteFilter": map[string]interface{}{"Block": []interface{}{"c"}},
+				}},
+			},
+			want: map[string]interface{}{
+				"WebsiteFilter": map[string]interface{}{"Block": []interface{}{"c"}},
+			},
+			wantOrigins: map[string]string{
+				"policies.WebsiteFilter.Block": "overrides",
+			},
+		},
+		{
+			name: "null removes lower keys",
+			layers: []policyLayer{
+				{Name: "low", Policies: map[string]interface{}{
+					"DisableTelemetry": true,
+					"Homepage":         map[string]interface{}{"URL": "https://low.example/", "Locked": true},
+				}},
+				{Name: "high", Policies: map[string]interface{}{
+					"DisableTelemetry": nil,
+					"Homepage":         map[string]interface{}{"Locked": nil},
+				}},
+			},
+			want: map[string]interface{}{
+				"Homepage": map[string]interface{}{"URL": "https://low.example/"},
+			},
+			wantOrigins: map[string]string{
+				"policies.Homepage.URL": "low",
+			},
+		},
+		{
+			name: "object replaces scalar",
+			layers: []policyLayer{
+				{Name: "low", Policies: map[string]interface{}{"Homepage": "https://low.example/"}},
+				{Name: "high", Policies: map[string]interface{}{
+					"Homepage": map[string]interface{}{"URL": "https://high.example/"},
+				}},
+			},
+			want: map[string]interface{}{
+				"Homepage": map[string]interface{}{"URL": "https://high.example/"},
+			},
+			wantOrigins: map[string]string{
+				"policies.Homepage.URL": "high",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := mergePolicyLayers(tt.layers)
+			if !reflect.DeepEqual(set.Policies, tt.want) {
+				t.Errorf("Policies = %v, want %v", set.Policies, tt.want)
+			}
+			if !reflect.DeepEqual(set.Origins, tt.wantOrigins) {
+				t.Errorf("Origins = %v, want %v", set.Origins, tt.wantOrigins)
+			}
+		})
+	}
+}
+
+func TestMergePolicyLayersNoAliasing(t *testing.T) {
+	low := map[string]interface{}{
+		"Homepage": map[string]interface{}{"URL": "https://low.example/"},
+		"SearchEngines": map[string]interface{}{
+			"Add": []interface{}{map[string]interface{}{"Name": "Intranet"}},
+		},
+	}
+	high := map[string]interface{}{
+		"SearchEngines": map[string]interface{}{
+			"Add": []interface{}{map[string]interface{}{"Name": "Wiki"}},
+		},
+	}
+	set := mergePolicyLayers([]policyLayer{
+		{Name: "low", Policies: low},
+		{Name: "high", Policies: high},
+	})
+
+	set.Policies["Homepage"].(map[string]interface{})["URL"] = "changed"
+	engines := set.Policies["SearchEngines"].(map[string]interface{})["Add"].([]interface{})
+	engines[0].(map[string]interface{})["Name"] = "changed"
+	engines[1].(map[string]interface{})["Name"] = "changed"
+
+	if got := low["Homepage"].(map[string]interface{})["URL"]; got != "https://low.example/" {
+		t.Errorf("low Homepage.URL = %v, the merged policies alias the layer", got)
+	}
+	lowEngine := low["SearchEngines"].(map[string]interface{})["Add"].([]interface{})[0]
+	if got := lowEngine.(map[string]interface{})["Name"]; got != "Intranet" {
+		t.Errorf("low engine name = %v, the merged policies alias the layer", got)
+	}
+	highEngine := high["SearchEngines"].(map[string]interface{})["Add"].([]interface{})[0]
+	if got := highEngine.(map[string]interface{})["Name"]; got != "Wiki" {
+		t.Errorf("high engine name = %v, the merged policies alias the layer", got)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// defaultPolicySchema is the policies schema shipped with the launcher. It uses
+// the format of policies-schema.json found in Floorp/Firefox sources, which
+// can be dropped in the data folder to validate against a newer release.
+//
+//go:embed res/policies-schema.json
+var defaultPolicySchema []byte
+
+// policySchema is a node of a policies schema. Types are the ones understood by
+// the Firefox policies validator: boolean, number, integer, string, URL,
+// URLorEmpty, origin, JSON, array and object. A node can accept several types.
+type policySchema struct {
+	Type                 policySchemaTypes        `json:"type"`
+	Enum                 []interface{}            `json:"enum"`
+	Items                *policySchema            `json:"items"`
+	Properties           map[string]*policySchema `json:"properties"`
+	PatternProperties    map[string]*policySchema `json:"patternProperties"`
+	AdditionalProperties json.RawMessage          `json:"additionalProperties"`
+	Required             []string                 `json:"required"`
+}
+
+// policySchemaTypes holds the type of a schema node, declared either as a
+// single string or as an array of strings.
+type policySchemaTypes []string
+
+func (t *policySchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = policySchemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.Errorf("invalid schema type %s", string(data))
+	}
+	*t = multiple
+	return nil
+}
+
+// policySchemaBuiltin is the source of the schema shipped with the launcher.
+const policySchemaBuiltin = "built-in"
+
+// policyError is a validation error located by its JSON path. Unknown is set
+// for policy names the schema does not declare.
+type policyError struct {
+	Path    string
+	Message string
+	Unknown bool
+}
+
+func (e policyError) String() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// fatal reports whether the error prevents the launch in strict mode. The
+// built-in schema only covers the common policies, so a policy name it does
+// not know may still be valid upstream and is only a warning.
+func (e policyError) fatal(schemaSource string) bool {
+	return !e.Unknown || schemaSource != policySchemaBuiltin
+}
+
+// loadPolicySchema returns the schema found in the data folder or the one
+// shipped with the launcher.
+func loadPolicySchema() (*policySchema, string, error) {
+	source := policySchemaBuiltin
+	raw := defaultPolicySchema
+
+	dataFile := utl.PathJoin(app.DataPath, "policies-schema.json")
+	if utl.Exists(dataFile) {
+		var err error
+		if raw, err = os.ReadFile(dataFile); err != nil {
+			return nil, "", errors.Wrap(err, "Cannot read policies schema")
+		}
+		source = dataFile
+	}
+
+	var schema policySchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, "", errors.Wrapf(err, "Cannot parse policies schema %s", source)
+	}
+
+	return &schema, source, nil
+}
+
+// validatePolicies checks policies against the schema and returns the errors
+// sorted by path.
+func validatePolicies(schema *policySchema, policies map[string]interface{}) []policyError {
+	var errs []policyError
+	for _, name := range sortedKeys(policies) {
+		policySchema, ok := schema.Properties[name]
+		if !ok {
+			errs = append(errs, policyError{
+				Path:    "policies." + name,
+				Message: fmt.Sprintf("unknown policy%s", suggestPolicyName(schema, name)),
+				Unknown: true,
+			})
+			continue
+		}
+		policySchema.validate(policies[name], "policies."+name, &errs)
+	}
+	return errs
+}
+
+func (s *policySchema) validate(value interface{}, path string, errs *[]policyError) {
+	if len(s.Type) == 0 {
+		return
+	}
+	if len(s.Type) == 1 {
+		s.validateType(s.Type[0], value, path, errs)
+		return
+	}
+
+	// Accept the value as soon as one of the types matches
+	var typeErrs []policyError
+	for _, typ := range s.Type {
+		var candidateErrs []policyError
+		s.validateType(typ, value, path, &candidateErrs)
+		if len(candidateErrs) == 0 {
+			return
+		}
+		switch {
+		case typeErrs == nil:
+			typeErrs = candidateErrs
+		case isTypeMismatch(candidateErrs, path):
+		case isTypeMismatch(typeErrs, path) || len(candidateErrs) < len(typeErrs):
+			typeErrs = candidateErrs
+		}
+	}
+	if isTypeMismatch(typeErrs, path) {
+		*errs = append(*errs, policyError{
+			Path:    path,
+			Message: fmt.Sprintf("expected %s, got %s", strings.Join(s.Type, " or "), describePolicyValue(value)),
+		})
+		return
+	}
+	*errs = append(*errs, typeErrs...)
+}
+
+func (s *policySchema) validateType(typ string, value interface{}, path string, errs *[]policyError) {
+	mismatch := func() {
+		*errs = append(*errs, policyError{
+			Path:    path,
+			Message: fmt.Sprintf("expected %s, got %s", typ, describePolicyValue(value)),
+		})
+	}
+
+	switch typ {
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+		case float64:
+			if v != 0 && v != 1 {
+				mismatch()
+			}
+		default:
+			mismatch()
+		}
+	case "number", "integer":
+		v, ok := value.(float64)
+		if !ok || (typ == "integer" && v != math.Trunc(v)) {
+			mismatch()
+			return
+		}
+		s.validateEnum(value, path, errs)
+	case "string":
+		if _, ok := value.(string); !ok {
+			mismatch()
+			return
+		}
+		s.validateEnum(value, path, errs)
+	case "URL", "URLorEmpty", "origin":
+		v, ok := value.(string)
+		if !ok {
+			mismatch()
+			return
+		}
+		if v == "" && typ == "URLorEmpty" {
+			return
+		}
+		u, err := url.Parse(v)
+		if err != nil || u.Scheme == "" {
+			*errs = append(*errs, policyError{Path: path, Message: fmt.Sprintf("invalid URL %q", v)})
+			return
+		}
+		if typ == "origin" && (u.Host == "" || (u.Path != "" && u.Path != "/")) {
+			*errs = append(*errs, policyError{Path: path, Message: fmt.Sprintf("invalid origin %q", v)})
+		}
+	case "JSON":
+		switch v := value.(type) {
+		case map[string]interface{}, []interface{}:
+			// Structured values are checked by the object or array type if any
+			if s.hasType("object") || s.hasType("array") {
+				mismatch()
+			}
+		case string:
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(v), &decoded); err != nil {
+				*errs = append(*errs, policyError{Path: path, Message: fmt.Sprintf("invalid JSON string: %v", err)})
+			}
+		default:
+			mismatch()
+		}
+	case "array":
+		v, ok := value.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		if s.Items == nil {
+			return
+		}
+		for i, item := range v {
+			s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "object":
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		s.validateObject(v, path, errs)
+	default:
+		log.Warn().Msgf("Unknown type %s in policies schema at %s", typ, path)
+	}
+}
+
+func (s *policySchema) validateObject(value map[string]interface{}, path string, errs *[]policyError) {
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			*errs = append(*errs, policyError{Path: path, Message: fmt.Sprintf("missing required property %s", name)})
+		}
+	}
+
+	for _, name := range sortedKeys(value) {
+		propertyPath := path + "." + name
+		if propertySchema, ok := s.Properties[name]; ok {
+			propertySchema.validate(value[name], propertyPath, errs)
+			continue
+		}
+
+		matched := false
+		for pattern, propertySchema := range s.PatternProperties {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Cannot compile pattern %s of policies schema", pattern)
+				matched = true
+				break
+			}
+			if re.MatchString(name) {
+				propertySchema.validate(value[name], propertyPath, errs)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		if len(s.AdditionalProperties) > 0 && string(s.AdditionalProperties) != "false" {
+			var additional policySchema
+			if err := json.Unmarshal(s.AdditionalProperties, &additional); err == nil {
+				additional.validate(value[name], propertyPath, errs)
+			}
+			continue
+		}
+
+		// Objects without declared properties accept anything
+		if len(s.Properties) == 0 && len(s.PatternProperties) == 0 {
+			continue
+		}
+
+		*errs = append(*errs, policyError{
+			Path:    propertyPath,
+			Message: fmt.Sprintf("unknown property%s", suggestName(s.Properties, name)),
+		})
+	}
+}
+
+func (s *policySchema) validateEnum(value interface{}, path string, errs *[]policyError) {
+	if len(s.Enum) == 0 {
+		return
+	}
+	allowed := make([]string, 0, len(s.Enum))
+	for _, candidate := range s.Enum {
+		if reflect.DeepEqual(candidate, value) {
+			return
+		}
+		allowed = append(allowed, fmt.Sprintf("%v", candidate))
+	}
+	*errs = append(*errs, policyError{
+		Path:    path,
+		Message: fmt.Sprintf("invalid value %s, allowed values are %s", describePolicyValue(value), strings.Join(allowed, ", ")),
+	})
+}
+
+func (s *policySchema) hasType(typ string) bool {
+	for _, candidate := range s.Type {
+		if candidate == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// isTypeMismatch reports if errs only contains a type error on path itself.
+func isTypeMismatch(errs []policyError, path string) bool {
+	return len(errs) == 1 && errs[0].Path == path && strings.HasPrefix(errs[0].Message, "expected ")
+}
+
+func describePolicyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func suggestPolicyName(schema *policySchema, name string) string {
+	return suggestName(schema.Properties, name)
+}
+
+// suggestName returns a hint with the closest known name in case of a typo.
+func suggestName(known map[string]*policySchema, name string) string {
+	best, bestDistance := "", 3
+	for candidate := range known {
+		if strings.EqualFold(candidate, name) {
+			return fmt.Sprintf(" (did you mean %s?)", candidate)
+		}
+		if d := levenshtein(strings.ToLower(candidate), strings.ToLower(name)); d < bestDistance || (d == bestDistance && best != "" && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", best)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
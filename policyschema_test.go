@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func builtinPolicySchema(t *testing.T) *policySchema {
+	t.Helper()
+	var schema policySchema
+	if err := json.Unmarshal(defaultPolicySchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func TestValidatePolicies(t *testing.T) {
+	schema := builtinPolicySchema(t)
+	tests := []struct {
+		name     string
+		policies string
+		want     []policyError
+	}{
+		{
+			name:     "valid",
+			policies: `{"DisableTelemetry": true, "Homepage": {"URL": "https://floorp.app/", "Locked": true, "StartPage": "homepage"}}`,
+		},
+		{
+			name:     "typo in policy name",
+			policies: `{"DisableTelemtry": true}`,
+			want:     []policyError{{Path: "policies.DisableTelemtry", Message: "unknown policy (did you mean DisableTelemetry?)", Unknown: true}},
+		},
+		{
+			name:     "policy name case",
+			policies: `{"disabletelemetry": true}`,
+			want:     []policyError{{Path: "policies.disabletelemetry", Message: "unknown policy (did you mean DisableTelemetry?)", Unknown: true}},
+		},
+		{
+			name:     "wrong value type",
+			policies: `{"DisableTelemetry": "yes"}`,
+			want:     []policyError{{Path: "policies.DisableTelemetry", Message: `expected boolean, got string "yes"`}},
+		},
+		{
+			name:     "bad URL",
+			policies: `{"Homepage": {"URL": "example"}}`,
+			want:     []policyError{{Path: "policies.Homepage.URL", Message: `invalid URL "example"`}},
+		},
+		{
+			name:     "unknown property",
+			policies: `{"Homepage": {"URL": "https://floorp.app/", "Locekd": true}}`,
+			want:     []policyError{{Path: "policies.Homepage.Locekd", Message: "unknown property (did you mean Locked?)"}},
+		},
+		{
+			name:     "invalid enum value",
+			policies: `{"Homepage": {"StartPage": "blank"}}`,
+			want: []policyError{{
+				Path:    "policies.Homepage.StartPage",
+				Message: `invalid value string "blank", allowed values are none, homepage, previous-session, homepage-locked`,
+			}},
+		},
+		{
+			name:     "unknown policy without suggestion",
+			policies: `{"Xyzzy": true}`,
+			want:     []policyError{{Path: "policies.Xyzzy", Message: "unknown policy", Unknown: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var policies map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.policies), &policies); err != nil {
+				t.Fatal(err)
+			}
+			if got := validatePolicies(schema, policies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validatePolicies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"locked", "locked", 0},
+		{"locked", "locekd", 2},
+		{"disabletelemetry", "disabletelemtry", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestName(t *testing.T) {
+	known := map[string]*policySchema{"URL": {}, "Locked": {}, "StartPage": {}}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"url", " (did you mean URL?)"},
+		{"Lockd", " (did you mean Locked?)"},
+		{"StratPage", " (did you mean StartPage?)"},
+		{"Additional", ""},
+	}
+	for _, tt := range tests {
+		if got := suggestName(known, tt.name); got != tt.want {
+			t.Errorf("suggestName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPoliciesStrict(t *testing.T) {
+	saved, strict := *app, cfg.StrictPolicies
+	t.Cleanup(func() {
+		*app, cfg.StrictPolicies = saved, strict
+	})
+	app.DataPath = t.TempDir()
+	cfg.StrictPolicies = true
+
+	tests := []struct {
+		name     string
+		schema   string
+		policies map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "unknown policy against built-in schema",
+			policies: map[string]interface{}{"Xyzzy": true},
+		},
+		{
+			name:     "wrong type against built-in schema",
+			policies: map[string]interface{}{"DisableTelemetry": "yes"},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown policy against data schema",
+			schema:   `{"type": "object", "properties": {"DisableTelemetry": {"type": "boolean"}}}`,
+			policies: map[string]interface{}{"Xyzzy": true},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schemaFile := filepath.Join(app.DataPath, "policies-schema.json")
+			os.Remove(schemaFile)
+			if tt.schema != "" {
+				writeFiles(t, map[string]string{schemaFile: tt.schema})
+			}
+
+			err := checkPolicies(&policySet{Policies: tt.policies})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkPolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
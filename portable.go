@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// Download modes of the strict portable mode. In data mode downloads default
+// to the downloads folder of the data folder, in locked mode they cannot be
+// saved elsewhere, in host mode the download settings are left untouched.
+const (
+	strictDownloadsData   = "data"
+	strictDownloadsLocked = "locked"
+	strictDownloadsHost   = "host"
+)
+
+// strictPortableEnvNames are the environment variables pointing Floorp to host
+// folders, redirected to the temp folder of the data folder.
+var strictPortableEnvNames = []string{"TEMP", "TMP"}
+
+// setupStrictPortable redirects the host-level writes of Floorp into the data
+// folder: temporary files, cache and downloads. Windows integrations writing
+// to the host (jump lists, shortcuts, default browser agent, restart
+// registration) are disabled. The temp folder is emptied when no other
+// instance is running.
+func setupStrictPortable(otherInstance bool) {
+	log.Info().Msg("Strict portable mode enabled")
+
+	tempFolder := utl.PathJoin(app.DataPath, "temp")
+	if !otherInstance {
+		if err := os.RemoveAll(tempFolder); err != nil {
+			log.Error().Err(err).Msgf("Cannot empty %s", tempFolder)
+		}
+	}
+	utl.CreateFolder(tempFolder)
+	for name, value := range strictPortableEnv(tempFolder) {
+		os.Setenv(name, value)
+	}
+
+	downloads := cfg.StrictDownloads
+	switch downloads {
+	case strictDownloadsData, strictDownloadsLocked, strictDownloadsHost:
+	default:
+		log.Warn().Msgf("Unknown strict_portable_downloads %q, using %q", downloads, strictDownloadsData)
+		downloads = strictDownloadsData
+	}
+	downloadsFolder := utl.PathJoin(app.DataPath, "downloads")
+	if downloads != strictDownloadsHost {
+		utl.CreateFolder(downloadsFolder)
+	}
+
+	cacheFolder := utl.CreateFolder(app.DataPath, "cache")
+	launchPrefs = append(launchPrefs, strictPortablePrefs(cacheFolder, downloadsFolder, downloads)...)
+
+	// Shortcuts are written to the host
+	if cfg.Shortcut.Mode != shortcutModeOff {
+		log.Warn().Msgf("Strict portable mode disables the %s shortcuts, set shortcut mode to %s to silence this warning", cfg.Shortcut.Mode, shortcutModeOff)
+		cfg.Shortcut.Mode = shortcutModeOff
+	}
+}
+
+// strictPortableEnv returns the environment variables redirecting temporary
+// files to tempFolder.
+func strictPortableEnv(tempFolder string) map[string]string {
+	env := make(map[string]string, len(strictPortableEnvNames))
+	for _, name := range strictPortableEnvNames {
+		env[name] = tempFolder
+	}
+	return env
+}
+
+// strictPortablePrefs returns the preferences keeping Floorp from writing to
+// the host, with downloads handled according to the download mode.
+func strictPortablePrefs(cacheFolder string, downloadsFolder string, downloads string) []mozillaPref {
+	prefs := []mozillaPref{
+		{Func: prefFuncLockPref, Name: "browser.cache.disk.parent_directory", Value: jsString(cacheFolder)},
+	}
+
+	switch downloads {
+	case strictDownloadsData:
+		prefs = append(prefs,
+			mozillaPref{Func: prefFuncDefaultPref, Name: "browser.download.dir", Value: jsString(downloadsFolder)},
+			mozillaPref{Func: prefFuncDefaultPref, Name: "browser.download.folderList", Value: "2"},
+		)
+	case strictDownloadsLocked:
+		prefs = append(prefs,
+			mozillaPref{Func: prefFuncLockPref, Name: "browser.download.dir", Value: jsString(downloadsFolder)},
+			mozillaPref{Func: prefFuncLockPref, Name: "browser.download.folderList", Value: "2"},
+		)
+	}
+
+	return append(prefs,
+		mozillaPref{Func: prefFuncLockPref, Name: "browser.shell.checkDefaultBrowser", Value: "false"},
+		mozillaPref{Func: prefFuncLockPref, Name: "browser.shell.shortcutFavicons", Value: "false"},
+		mozillaPref{Func: prefFuncLockPref, Name: "browser.taskbar.lists.enabled", Value: "false"},
+		mozillaPref{Func: prefFuncLockPref, Name: "default-browser-agent.enabled", Value: "false"},
+		mozillaPref{Func: prefFuncLockPref, Name: "toolkit.winRegisterApplicationRestart", Value: "false"},
+	)
+}
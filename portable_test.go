@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrictPortableEnv(t *testing.T) {
+	tempFolder := `E:\Floorp\data\temp`
+	want := map[string]string{"TEMP": tempFolder, "TMP": tempFolder}
+	if got := strictPortableEnv(tempFolder); !reflect.DeepEqual(got, want) {
+		t.Errorf("strictPortableEnv = %v, want %v", got, want)
+	}
+}
+
+func TestStrictPortablePrefs(t *testing.T) {
+	cacheFolder := `E:\Floorp\data\cache`
+	downloadsFolder := `E:\Floorp\data\downloads`
+
+	hostPrefs := []string{
+		`lockPref("browser.cache.disk.parent_directory", "E:\\Floorp\\data\\cache");`,
+		`lockPref("browser.shell.checkDefaultBrowser", false);`,
+		`lockPref("browser.shell.shortcutFavicons", false);`,
+		`lockPref("browser.taskbar.lists.enabled", false);`,
+		`lockPref("default-browser-agent.enabled", false);`,
+		`lockPref("toolkit.winRegisterApplicationRestart", false);`,
+	}
+	tests := []struct {
+		downloads string
+		want      []string
+	}{
+		{
+			downloads: strictDownloadsData,
+			want: []string{
+				`defaultPref("browser.download.dir", "E:\\Floorp\\data\\downloads");`,
+				`defaultPref("browser.download.folderList", 2);`,
+			},
+		},
+		{
+			downloads: strictDownloadsLocked,
+			want: []string{
+				`lockPref("browser.download.dir", "E:\\Floorp\\data\\downloads");`,
+				`lockPref("browser.download.folderList", 2);`,
+			},
+		},
+		{
+			downloads: strictDownloadsHost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.downloads, func(t *testing.T) {
+			statements := map[string]bool{}
+			for _, pref := range strictPortablePrefs(cacheFolder, downloadsFolder, tt.downloads) {
+				statements[pref.Statement()] = true
+			}
+			for _, statement := range append(append([]string{}, hostPrefs...), tt.want...) {
+				if !statements[statement] {
+					t.Errorf("missing %s", statement)
+				}
+				delete(statements, statement)
+			}
+			if len(statements) > 0 {
+				t.Errorf("unexpected preferences %v", statements)
+			}
+		})
+	}
+}
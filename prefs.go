@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// Autoconfig functions used to set a preference.
+const (
+	prefFuncPref        = "pref"
+	prefFuncDefaultPref = "defaultPref"
+	prefFuncLockPref    = "lockPref"
+	prefFuncClearPref   = "clearPref"
+)
+
+// prefsConfig holds the preferences written to portapps.cfg. String values
+// are templates receiving mozillaCfgData (e.g. "{{ .DataPath }}\downloads").
+type prefsConfig struct {
+	Pref        map[string]interface{} `yaml:"pref" mapstructure:"pref"`
+	DefaultPref map[string]interface{} `yaml:"default_pref" mapstructure:"default_pref"`
+	LockPref    map[string]interface{} `yaml:"lock_pref" mapstructure:"lock_pref"`
+	ClearPref   []string               `yaml:"clear_pref" mapstructure:"clear_pref"`
+}
+
+// mozillaPref is a single autoconfig statement.
+type mozillaPref struct {
+	Func  string
+	Name  string
+	Value string
+}
+
+// Statement returns the JavaScript statement setting the preference.
+func (p mozillaPref) Statement() string {
+	if p.Func == prefFuncClearPref {
+		return fmt.Sprintf("%s(%s);", p.Func, jsString(p.Name))
+	}
+	return fmt.Sprintf("%s(%s, %s);", p.Func, jsString(p.Name), p.Value)
+}
+
+// mozillaPrefsFile is a preferences file of the prefs.d folder.
+type mozillaPrefsFile struct {
+	Name    string
+	Content string
+}
+
+// mozillaCfgData is passed to the portapps.cfg template, to config preference
+// values and to the files of the prefs.d folder.
+type mozillaCfgData struct {
+	RootPath    string
+	AppPath     string
+	DataPath    string
+	ProfilePath string
+	Profile     string
+	Version     string
+	Prefs       []mozillaPref
+	Files       []mozillaPrefsFile
+}
+
+// launchPrefs are preferences set by the launcher for the current launch only.
+var launchPrefs []mozillaPref
+
+var mozillaCfgTpl = template.Must(template.New("mozillaCfg").Parse(`// Extensions scopes
+lockPref("extensions.enabledScopes", 4);
+lockPref("extensions.autoDisableScopes", 3);
+
+// Don't show 'know your rights' on first run
+pref("browser.rights.3.shown", true);
+
+// Don't show WhatsNew on first run after every update
+pref("browser.startup.homepage_override.mstone", "ignore");
+{{- if .Prefs }}
+
+// Config
+{{- range .Prefs }}
+{{ .Statement }}
+{{- end }}
+{{- end }}
+{{- range .Files }}
+
+// {{ .Name }}
+{{ .Content }}
+{{- end }}
+`))
+
+// createMozillaCfg writes the portapps.cfg autoconfig file.
+func createMozillaCfg(profileFolder string) error {
+	data := mozillaCfgData{
+		RootPath:    app.RootPath,
+		AppPath:     app.AppPath,
+		DataPath:    app.DataPath,
+		ProfilePath: profileFolder,
+		Profile:     cfg.Profile,
+		Version:     app.Info.Version,
+	}
+
+	var err error
+	if data.Prefs, err = configPrefs(cfg.Prefs, data); err != nil {
+		return err
+	}
+	data.Prefs = append(data.Prefs, launchPrefs...)
+	data.Files = prefsFiles(utl.PathJoin(app.DataPath, "prefs.d"), data)
+
+	mozillaCfgFile, err := os.Create(utl.PathJoin(app.AppPath, "portapps.cfg"))
+	if err != nil {
+		return errors.Wrap(err, "Cannot create portapps.cfg")
+	}
+	defer mozillaCfgFile.Close()
+
+	if err := mozillaCfgTpl.Execute(mozillaCfgFile, data); err != nil {
+		return errors.Wrap(err, "Cannot write portapps.cfg")
+	}
+
+	return nil
+}
+
+// configPrefs returns the preferences set in the configuration, sorted by
+// function and name.
+func configPrefs(prefs prefsConfig, data mozillaCfgData) ([]mozillaPref, error) {
+	var result []mozillaPref
+
+	sets := []struct {
+		fn    string
+		prefs map[string]interface{}
+	}{
+		{prefFuncDefaultPref, prefs.DefaultPref},
+		{prefFuncPref, prefs.Pref},
+		{prefFuncLockPref, prefs.LockPref},
+	}
+	for _, set := range sets {
+		names := make([]string, 0, len(set.prefs))
+		for name := range set.prefs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value, err := prefValue(set.prefs[name], data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid value for %s %s", set.fn, name)
+			}
+			result = append(result, mozillaPref{Func: set.fn, Name: name, Value: value})
+		}
+	}
+
+	for _, name := range prefs.ClearPref {
+		result = append(result, mozillaPref{Func: prefFuncClearPref, Name: name})
+	}
+
+	return result, nil
+}
+
+// prefValue returns the JavaScript literal of a preference value.
+func prefValue(value interface{}, data mozillaCfgData) (string, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, err := executePrefTemplate("value", v, data)
+		if err != nil {
+			return "", err
+		}
+		return jsString(expanded), nil
+	case bool:
+		return fmt.Sprintf("%t", v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	case float32, float64:
+		return fmt.Sprintf("%v", v), nil
+	default:
+		return "", errors.Errorf("unsupported type %T", value)
+	}
+}
+
+// prefsFiles returns the *.js files of folder rendered as templates, sorted
+// by name. Files that cannot be read or rendered are skipped.
+func prefsFiles(folder string, data mozillaCfgData) []mozillaPrefsFile {
+	matches, err := filepath.Glob(filepath.Join(folder, "*.js"))
+	if err != nil {
+		log.Error().Err(err).Msgf("Cannot list preferences files in %s", folder)
+		return nil
+	}
+	sort.Strings(matches)
+
+	var files []mozillaPrefsFile
+	for _, match := range matches {
+		raw, err := os.ReadFile(match)
+		if err != nil {
+			log.Error().Err(err).Msgf("Cannot read preferences file %s", match)
+			continue
+		}
+
+		name := filepath.Base(match)
+		content, err := executePrefTemplate(name, string(raw), data)
+		if err != nil {
+			log.Error().Err(err).Msgf("Cannot render preferences file %s", match)
+			continue
+		}
+
+		log.Info().Msgf("Adding preferences from %s", match)
+		files = append(files, mozillaPrefsFile{
+			Name:    name,
+			Content: strings.TrimRight(content, "\r\n"),
+		})
+	}
+
+	return files
+}
+
+func executePrefTemplate(name string, text string, data mozillaCfgData) (string, error) {
+	tpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// jsString returns s as a quoted JavaScript string literal.
+func jsString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
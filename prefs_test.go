@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefValue(t *testing.T) {
+	data := mozillaCfgData{DataPath: `D:\Floorp\data`, Profile: "default"}
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "string", value: "homepage", want: `"homepage"`},
+		{name: "quotes", value: `say "hi"`, want: `"say \"hi\""`},
+		{name: "backslashes", value: `C:\Temp\new`, want: `"C:\\Temp\\new"`},
+		{name: "control characters", value: "a\nb\tc", want: `"a\nb\tc"`},
+		{name: "template", value: `{{ .DataPath }}\downloads`, want: `"D:\\Floorp\\data\\downloads"`},
+		{name: "template with quotes", value: `{{ .Profile }} "x"`, want: `"default \"x\""`},
+		{name: "bool", value: true, want: "true"},
+		{name: "int", value: 3, want: "3"},
+		{name: "negative int64", value: int64(-1), want: "-1"},
+		{name: "float", value: 1.5, want: "1.5"},
+		{name: "invalid template", value: "{{ .Missing", wantErr: true},
+		{name: "unknown field", value: "{{ .Missing }}", wantErr: true},
+		{name: "unsupported type", value: []interface{}{"a"}, wantErr: true},
+		{name: "nil", value: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prefValue(tt.value, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prefValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("prefValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigPrefs(t *testing.T) {
+	prefs := prefsConfig{
+		Pref: map[string]interface{}{
+			"browser.download.dir":      `{{ .DataPath }}\downloads`,
+			"browser.cache.disk.enable": false,
+		},
+		DefaultPref: map[string]interface{}{"browser.startup.page": 3},
+		LockPref:    map[string]interface{}{"browser.download.useDownloadDir": true},
+		ClearPref:   []string{"browser.startup.homepage"},
+	}
+
+	got, err := configPrefs(prefs, mozillaCfgData{DataPath: `D:\Floorp\data`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`defaultPref("browser.startup.page", 3);`,
+		`pref("browser.cache.disk.enable", false);`,
+		`pref("browser.download.dir", "D:\\Floorp\\data\\downloads");`,
+		`lockPref("browser.download.useDownloadDir", true);`,
+		`clearPref("browser.startup.homepage");`,
+	}
+	statements := make([]string, 0, len(got))
+	for _, pref := range got {
+		statements = append(statements, pref.Statement())
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("configPrefs() = %q, want %q", statements, want)
+	}
+
+	if _, err := configPrefs(prefsConfig{Pref: map[string]interface{}{"a": map[string]interface{}{}}}, mozillaCfgData{}); err == nil {
+		t.Error("configPrefs() accepted an object value")
+	}
+}
+
+func TestMozillaPrefStatementEscapesName(t *testing.T) {
+	pref := mozillaPref{Func: prefFuncPref, Name: `a"); evil("`, Value: "true"}
+	if got, want := pref.Statement(), `pref("a\"); evil(\"", true);`; got != want {
+		t.Errorf("Statement() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+)
+
+// presetConfig is a named set of arguments and environment variables selected
+// with --preset.
+type presetConfig struct {
+	Args []string          `yaml:"args" mapstructure:"args"`
+	Env  map[string]string `yaml:"env" mapstructure:"env"`
+}
+
+// builtinPresets are available without configuration, a preset of the same
+// name in the configuration replaces them.
+var builtinPresets = map[string]presetConfig{
+	"kiosk":    {Args: []string{"--kiosk"}},
+	"private":  {Args: []string{"--private-window"}},
+	"headless": {Args: []string{"--headless"}},
+	"devtools": {Args: []string{"--devtools", "--jsconsole"}},
+}
+
+// launchArgs returns the arguments of the configuration and of the selected
+// presets (comma-separated), and the environment variables of the presets.
+func launchArgs(presetNames string, profileFolder string) ([]string, map[string]string, error) {
+	var args []string
+	env := map[string]string{}
+	for _, arg := range cfg.Args {
+		args = append(args, expandEnvValue(arg, profileFolder))
+	}
+
+	for _, name := range strings.Split(presetNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		preset, ok := cfg.Presets[name]
+		if !ok {
+			if preset, ok = builtinPresets[name]; !ok {
+				return nil, nil, errors.Errorf("Unknown preset %s, available presets: %s", name, strings.Join(presetNamesList(), ", "))
+			}
+		}
+
+		log.Info().Msgf("Using preset %s", name)
+		for _, arg := range preset.Args {
+			args = append(args, expandEnvValue(arg, profileFolder))
+		}
+		for key, value := range preset.Env {
+			env[key] = value
+		}
+	}
+
+	return args, env, nil
+}
+
+// presetNamesList returns the names of the built-in and configured presets.
+func presetNamesList() []string {
+	names := make([]string, 0, len(builtinPresets)+len(cfg.Presets))
+	for name := range builtinPresets {
+		names = append(names, name)
+	}
+	for name := range cfg.Presets {
+		if _, ok := builtinPresets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLaunchArgs(t *testing.T) {
+	args, presets, env, dataPath := cfg.Args, cfg.Presets, cfg.Env, app.DataPath
+	t.Cleanup(func() {
+		cfg.Args, cfg.Presets, cfg.Env, app.DataPath = args, presets, env, dataPath
+	})
+
+	t.Setenv("FLOORP_TEST_PORT", "9222")
+	app.DataPath = `E:\Floorp\data`
+	cfg.Args = []string{"--profile-log=${DATA}\\logs"}
+	cfg.Presets = map[string]presetConfig{
+		"private": {Args: []string{"--private"}},
+		"debug": {
+			Args: []string{"--start-debugger-server", "${FLOORP_TEST_PORT}", "--new-tab=${PROFILE}"},
+			Env:  map[string]string{"MOZ_LOG": "timestamp,sync", "MOZ_LOG_FILE": "${DATA}\\logs\\moz.log"},
+		},
+	}
+	cfg.Env = map[string]string{"MOZ_LOG": "timestamp"}
+
+	tests := []struct {
+		name     string
+		presets  string
+		wantArgs []string
+		wantEnv  map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "no preset",
+			wantArgs: []string{`--profile-log=E:\Floorp\data\logs`},
+			wantEnv:  map[string]string{},
+		},
+		{
+			name:     "built-in preset",
+			presets:  "kiosk",
+			wantArgs: []string{`--profile-log=E:\Floorp\data\logs`, "--kiosk"},
+			wantEnv:  map[string]string{},
+		},
+		{
+			name:     "config preset overrides built-in",
+			presets:  "private",
+			wantArgs: []string{`--profile-log=E:\Floorp\data\logs`, "--private"},
+			wantEnv:  map[string]string{},
+		},
+		{
+			name:     "comma-separated with spaces",
+			presets:  " headless, ,debug ",
+			wantArgs: []string{`--profile-log=E:\Floorp\data\logs`, "--headless", "--start-debugger-server", "9222", `--new-tab=E:\Floorp\data\profile`},
+			wantEnv:  map[string]string{"MOZ_LOG": "timestamp,sync", "MOZ_LOG_FILE": `${DATA}\logs\moz.log`},
+		},
+		{
+			name:    "unknown preset",
+			presets: "kiosk,kisok",
+			wantErr: "Unknown preset kisok, available presets: debug, devtools, headless, kiosk, private",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotArgs, gotEnv, err := launchArgs(tt.presets, `E:\Floorp\data\profile`)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("launchArgs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("launchArgs() args = %q, want %q", gotArgs, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(gotEnv, tt.wantEnv) {
+				t.Errorf("launchArgs() env = %v, want %v", gotEnv, tt.wantEnv)
+			}
+			if want := map[string]string{"MOZ_LOG": "timestamp"}; !reflect.DeepEqual(cfg.Env, want) {
+				t.Errorf("launchArgs() changed configuration env to %v", cfg.Env)
+			}
+		})
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/mozlz4"
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// relocationFormat is the encoding of a profile file embedding absolute paths.
+type relocationFormat int
+
+const (
+	// relocationPlain files contain raw paths (e.g. compatibility.ini)
+	relocationPlain relocationFormat = iota
+	// relocationJSON files contain paths in JSON strings
+	relocationJSON
+	// relocationPrefs files contain paths in user_pref() string arguments
+	relocationPrefs
+	// relocationMozLz4 files are mozLz4 compressed JSON
+	relocationMozLz4
+)
+
+// relocationTarget describes profile files embedding absolute paths.
+type relocationTarget struct {
+	Pattern string
+	Format  relocationFormat
+}
+
+// relocationTargets lists the profile files rewritten when the portable folder
+// is moved. Patterns are relative to the profile folder.
+var relocationTargets = []relocationTarget{
+	{Pattern: "addonStartup.json.lz4", Format: relocationMozLz4},
+	{Pattern: "extensions.json", Format: relocationJSON},
+	{Pattern: "prefs.js", Format: relocationPrefs},
+	{Pattern: "handlers.json", Format: relocationJSON},
+	{Pattern: "pkcs11.txt", Format: relocationPlain},
+	{Pattern: "compatibility.ini", Format: relocationPlain},
+	{Pattern: "sessionstore.jsonlz4", Format: relocationMozLz4},
+	{Pattern: "sessionstore-backups/*.jsonlz4", Format: relocationMozLz4},
+}
+
+// pathRelocation is a previous location and its current counterpart.
+type pathRelocation struct {
+	Prev string
+	Curr string
+}
+
+// portableLocation is a location of the portable folder.
+type portableLocation struct {
+	RootPath string `json:"root_path"`
+	AppPath  string `json:"app_path"`
+	DataPath string `json:"data_path"`
+}
+
+// currentLocation returns the current location of the portable folder.
+func currentLocation() portableLocation {
+	return portableLocation{RootPath: app.RootPath, AppPath: app.AppPath, DataPath: app.DataPath}
+}
+
+// relocationPaths returns the locations that moved since the previous launch.
+func relocationPaths() []pathRelocation {
+	return relocationsFrom(portableLocation{RootPath: app.Prev.RootPath, AppPath: app.Prev.AppPath, DataPath: app.Prev.DataPath})
+}
+
+// relocationsFrom returns the locations that moved since the portable folder
+// was at prev.
+func relocationsFrom(prev portableLocation) []pathRelocation {
+	if prev.RootPath == "" || prev.RootPath == app.RootPath {
+		return nil
+	}
+
+	relocations := []pathRelocation{{Prev: prev.RootPath, Curr: app.RootPath}}
+
+	// The app and data folders can live outside of the root folder
+	for _, relocation := range []pathRelocation{
+		{Prev: prev.AppPath, Curr: app.AppPath},
+		{Prev: prev.DataPath, Curr: app.DataPath},
+	} {
+		if relocation.Prev == "" || relocation.Prev == relocation.Curr || isSubPath(prev.RootPath, relocation.Prev) {
+			continue
+		}
+		relocations = append(relocations, relocation)
+	}
+
+	return relocations
+}
+
+// relocateProfile rewrites the absolute paths of the previous location of the
+// portable folder in the profile files. The current location then becomes the
+// previous one, even on errors, so the files already rewritten are not
+// rewritten again by a later call (e.g. on a kiosk restart).
+func relocateProfile(profileFolder string) error {
+	defer markProfileRelocated()
+	return relocateProfileFrom(profileFolder, relocationPaths(), nil)
+}
+
+// markProfileRelocated records that the profile matches the current location.
+func markProfileRelocated() {
+	app.Prev.RootPath, app.Prev.AppPath, app.Prev.DataPath = app.RootPath, app.AppPath, app.DataPath
+}
+
+// relocateProfileFrom applies relocations to the profile files, or only to the
+// ones whose path relative to the profile is accepted by include if not nil.
+func relocateProfileFrom(profileFolder string, relocations []pathRelocation, include func(rel string) bool) error {
+	if len(relocations) == 0 {
+		return nil
+	}
+	for _, relocation := range relocations {
+		log.Info().Msgf("Relocating profile paths from %s to %s", relocation.Prev, relocation.Curr)
+	}
+
+	var errs []string
+	for _, target := range relocationTargets {
+		matches, err := filepath.Glob(filepath.Join(profileFolder, filepath.FromSlash(target.Pattern)))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, match := range matches {
+			if include != nil {
+				if rel, err := filepath.Rel(profileFolder, match); err != nil || !include(rel) {
+					continue
+				}
+			}
+			if err := relocateFile(match, target.Format, relocations); err != nil {
+				log.Error().Err(err).Msgf("Cannot relocate %s", match)
+				errs = append(errs, match)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Errorf("cannot relocate %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// relocateFile rewrites a single profile file.
+func relocateFile(filename string, format relocationFormat, relocations []pathRelocation) error {
+	var raw []byte
+	var err error
+	if format == relocationMozLz4 {
+		raw, err = mozlz4.ReadFile(filename)
+	} else {
+		raw, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	var content string
+	var count int
+	switch format {
+	case relocationJSON, relocationMozLz4:
+		if !json.Valid(raw) {
+			return errors.New("invalid JSON content")
+		}
+		content, count = relocateJSONStrings(string(raw), relocations)
+	case relocationPrefs:
+		content, count = relocatePrefsStrings(string(raw), relocations)
+	default:
+		content, count = relocateText(string(raw), relocations)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	log.Info().Msgf("Rewriting %d path(s) in %s", count, filename)
+	if format == relocationMozLz4 {
+		return mozlz4.WriteFile(filename, []byte(content), 0644)
+	}
+
+	return os.WriteFile(filename, []byte(content), 0644)
+}
+
+// relocateJSONStrings rewrites the string values of a JSON document. Only the
+// strings holding a relocated path or file URL are re-encoded, keys and the
+// rest of the document are kept byte for byte.
+func relocateJSONStrings(content string, relocations []pathRelocation) (string, int) {
+	var out strings.Builder
+	count := 0
+	last := 0
+
+	for i := 0; i < len(content); i++ {
+		if content[i] != '"' {
+			continue
+		}
+		end := jsonStringEnd(content, i)
+		if end < 0 {
+			break
+		}
+
+		token := content[i:end]
+		if !isJSONKey(content, end) {
+			if relocated, ok := relocateJSONToken(token, relocations); ok {
+				out.WriteString(content[last:i])
+				out.WriteString(relocated)
+				last = end
+				count++
+			}
+		}
+		i = end - 1
+	}
+
+	if count == 0 {
+		return content, 0
+	}
+	out.WriteString(content[last:])
+	return out.String(), count
+}
+
+// relocatePrefsStrings rewrites the string values of user_pref() lines.
+func relocatePrefsStrings(content string, relocations []pathRelocation) (string, int) {
+	lines := strings.SplitAfter(content, "\n")
+	count := 0
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "user_pref(") {
+			continue
+		}
+		var n int
+		if lines[i], n = relocateJSONStrings(line, relocations); n > 0 {
+			count += n
+		}
+	}
+	return strings.Join(lines, ""), count
+}
+
+// relocateJSONToken relocates a quoted JSON string.
+func relocateJSONToken(token string, relocations []pathRelocation) (string, bool) {
+	var value string
+	if err := json.Unmarshal([]byte(token), &value); err != nil {
+		return token, false
+	}
+
+	relocated, ok := relocateString(value, relocations)
+	if !ok {
+		return token, false
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(relocated); err != nil {
+		return token, false
+	}
+	return strings.TrimRight(buf.String(), "\n"), true
+}
+
+// jsonStringEnd returns the index following the closing quote of the string
+// starting at start, or -1 if it is not terminated.
+func jsonStringEnd(content string, start int) int {
+	for i := start + 1; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// isJSONKey reports if the string ending at end is an object key.
+func isJSONKey(content string, end int) bool {
+	for i := end; i < len(content); i++ {
+		switch content[i] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case ':':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// relocateString relocates a string holding a path (D:\Floorp\data or
+// D:/Floorp/data) or containing a file URL (file:///D:/Floorp/data,
+// jar:file:///D:/Floorp/data/x.xpi!/). Only true prefixes are replaced.
+func relocateString(value string, relocations []pathRelocation) (string, bool) {
+	for _, relocation := range relocations {
+		if relocated, ok := relocatePath(value, relocation); ok {
+			return relocated, true
+		}
+	}
+
+	const fileScheme = "file:///"
+	idx := strings.Index(strings.ToLower(value), fileScheme)
+	if idx < 0 {
+		return value, false
+	}
+	start := idx + len(fileScheme)
+	for _, relocation := range relocations {
+		if relocated, ok := relocateFileURLPath(value[start:], relocation); ok {
+			return value[:start] + relocated, true
+		}
+	}
+
+	return value, false
+}
+
+// relocatePath replaces the previous location at the start of a path, keeping
+// the separator style of the path. Drive letters and names are compared
+// case-insensitively like Windows does.
+func relocatePath(value string, relocation pathRelocation) (string, bool) {
+	prev := trimPathSeparators(relocation.Prev)
+	if prev == "" || len(value) < len(prev) {
+		return value, false
+	}
+
+	head, rest := value[:len(prev)], value[len(prev):]
+	if !strings.EqualFold(utl.FormatWindowsPath(head), utl.FormatWindowsPath(prev)) {
+		return value, false
+	}
+	if rest != "" && rest[0] != '\\' && rest[0] != '/' {
+		return value, false
+	}
+
+	curr := trimPathSeparators(relocation.Curr)
+	if strings.Contains(head, "/") || (!strings.Contains(head, `\`) && strings.HasPrefix(rest, "/")) {
+		curr = utl.FormatUnixPath(curr)
+	} else {
+		curr = utl.FormatWindowsPath(curr)
+	}
+	return curr + rest, true
+}
+
+// relocateFileURLPath replaces the previous location at the start of the
+// percent-encoded path of a file URL.
+func relocateFileURLPath(encoded string, relocation pathRelocation) (string, bool) {
+	prev := utl.FormatUnixPath(trimPathSeparators(relocation.Prev))
+	if prev == "" {
+		return encoded, false
+	}
+
+	decoded, consumed := decodeURLPrefix(encoded, len(prev))
+	if len(decoded) != len(prev) || !strings.EqualFold(decoded, prev) {
+		return encoded, false
+	}
+
+	rest := encoded[consumed:]
+	if rest != "" && !strings.ContainsRune("/!?#", rune(rest[0])) {
+		return encoded, false
+	}
+
+	curr := utl.FormatUnixPath(trimPathSeparators(relocation.Curr))
+	return encodeFileURLPath(curr) + rest, true
+}
+
+// decodeURLPrefix percent-decodes encoded until size bytes are decoded and
+// returns the decoded bytes with the number of encoded bytes consumed.
+func decodeURLPrefix(encoded string, size int) (string, int) {
+	var decoded strings.Builder
+	i := 0
+	for i < len(encoded) && decoded.Len() < size {
+		if encoded[i] == '%' && i+2 < len(encoded) && isHex(encoded[i+1]) && isHex(encoded[i+2]) {
+			decoded.WriteByte(unhex(encoded[i+1])<<4 | unhex(encoded[i+2]))
+			i += 3
+			continue
+		}
+		decoded.WriteByte(encoded[i])
+		i++
+	}
+	return decoded.String(), i
+}
+
+// encodeFileURLPath percent-encodes a path for a file URL. Unreserved and
+// path characters are kept, everything else (spaces, #, %, ?, non-ASCII) is
+// encoded as UTF-8 bytes.
+func encodeFileURLPath(path string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var encoded strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if isURLPathChar(c) {
+			encoded.WriteByte(c)
+			continue
+		}
+		encoded.WriteByte('%')
+		encoded.WriteByte(hexDigits[c>>4])
+		encoded.WriteByte(hexDigits[c&15])
+	}
+	return encoded.String()
+}
+
+func isURLPathChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!$&'()*+,;=:@/", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// relocateText replaces Windows and Unix forms of the previous location in
+// raw text, when followed by a separator or a character that cannot continue
+// a path (quote, end of line...).
+func relocateText(content string, relocations []pathRelocation) (string, int) {
+	count := 0
+	for _, relocation := range relocations {
+		for _, format := range []func(string) string{utl.FormatWindowsPath, utl.FormatUnixPath} {
+			var n int
+			content, n = replacePathPrefix(content, format(trimPathSeparators(relocation.Prev)), format(trimPathSeparators(relocation.Curr)))
+			count += n
+		}
+	}
+	return content, count
+}
+
+func replacePathPrefix(content string, prev string, curr string) (string, int) {
+	if prev == "" {
+		return content, 0
+	}
+
+	var out strings.Builder
+	lower, lowerPrev := asciiLower(content), asciiLower(prev)
+	count, last := 0, 0
+	for i := 0; ; {
+		idx := strings.Index(lower[i:], lowerPrev)
+		if idx < 0 {
+			break
+		}
+		start, end := i+idx, i+idx+len(prev)
+		i = end
+		if end < len(content) && !strings.ContainsRune("\\/'\"\r\n\t;,)]}>!?#", rune(content[end])) {
+			continue
+		}
+		out.WriteString(content[last:start])
+		out.WriteString(curr)
+		last = end
+		count++
+	}
+
+	if count == 0 {
+		return content, 0
+	}
+	out.WriteString(content[last:])
+	return out.String(), count
+}
+
+// asciiLower lowercases ASCII letters only, keeping byte offsets unchanged.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func trimPathSeparators(path string) string {
+	return strings.TrimRight(path, `\/`)
+}
+
+// isSubPath reports if path is parent or one of its descendants.
+func isSubPath(parent string, path string) bool {
+	parent = strings.TrimRight(strings.ToLower(utl.FormatWindowsPath(parent)), `\`)
+	path = strings.ToLower(utl.FormatWindowsPath(path))
+	return path == parent || strings.HasPrefix(path, parent+`\`)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelocateJSONStrings(t *testing.T) {
+	relocations := []pathRelocation{{Prev: `D:\Floorp`, Curr: `E:\Portable Apps\Flörp`}}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		count   int
+	}{
+		{
+			name:    "windows path",
+			content: `{"path":"D:\\Floorp\\data\\profile\\default"}`,
+			want:    `{"path":"E:\\Portable Apps\\Flörp\\data\\profile\\default"}`,
+			count:   1,
+		},
+		{
+			name:    "drive letter case",
+			content: `{"path":"d:\\floorp\\data"}`,
+			want:    `{"path":"E:\\Portable Apps\\Flörp\\data"}`,
+			count:   1,
+		},
+		{
+			name:    "unix separators",
+			content: `{"path":"D:/Floorp/data"}`,
+			want:    `{"path":"E:/Portable Apps/Flörp/data"}`,
+			count:   1,
+		},
+		{
+			name:    "exact path",
+			content: `{"path":"D:\\Floorp"}`,
+			want:    `{"path":"E:\\Portable Apps\\Flörp"}`,
+			count:   1,
+		},
+		{
+			name:    "prefix only",
+			content: `{"path":"D:\\FloorpOld\\data","other":"D:\\Floorp.bak"}`,
+			want:    `{"path":"D:\\FloorpOld\\data","other":"D:\\Floorp.bak"}`,
+		},
+		{
+			name:    "keys are kept",
+			content: `{"D:\\Floorp\\data": "D:\\Floorp\\data"}`,
+			want:    `{"D:\\Floorp\\data": "E:\\Portable Apps\\Flörp\\data"}`,
+			count:   1,
+		},
+		{
+			name:    "file URL",
+			content: `{"uri":"file:///D:/Floorp/data/a%20b.html"}`,
+			want:    `{"uri":"file:///E:/Portable%20Apps/Fl%C3%B6rp/data/a%20b.html"}`,
+			count:   1,
+		},
+		{
+			name:    "jar URL",
+			content: `{"uri":"jar:file:///D:/Floorp/data/ext.xpi!/manifest.json"}`,
+			want:    `{"uri":"jar:file:///E:/Portable%20Apps/Fl%C3%B6rp/data/ext.xpi!/manifest.json"}`,
+			count:   1,
+		},
+		{
+			name:    "html characters are not escaped",
+			content: `{"path":"D:\\Floorp\\a&b<c>"}`,
+			want:    `{"path":"E:\\Portable Apps\\Flörp\\a&b<c>"}`,
+			count:   1,
+		},
+		{
+			name:    "escaped unicode",
+			content: `{"path":"D:\\Floorp\\caf\u00e9"}`,
+			want:    `{"path":"E:\\Portable Apps\\Flörp\\café"}`,
+			count:   1,
+		},
+		{
+			name:    "unrelated strings",
+			content: `{"name":"Floorp","n":1,"list":["C:\\Floorp"]}`,
+			want:    `{"name":"Floorp","n":1,"list":["C:\\Floorp"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := relocateJSONStrings(tt.content, relocations)
+			if got != tt.want || count != tt.count {
+				t.Errorf("relocateJSONStrings() = %s (%d), want %s (%d)", got, count, tt.want, tt.count)
+			}
+		})
+	}
+}
+
+func TestRelocateFileURLPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		relocation pathRelocation
+		encoded    string
+		want       string
+		ok         bool
+	}{
+		{
+			name:       "plain",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\Floorp`},
+			encoded:    "D:/Floorp/data/index.html",
+			want:       "E:/Floorp/data/index.html",
+			ok:         true,
+		},
+		{
+			name:       "drive letter case",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\Floorp`},
+			encoded:    "d:/floorp/data",
+			want:       "E:/Floorp/data",
+			ok:         true,
+		},
+		{
+			name:       "percent-encoded previous path",
+			relocation: pathRelocation{Prev: `D:\My Apps\Flörp #1`, Curr: `E:\Floorp`},
+			encoded:    "D:/My%20Apps/Fl%C3%B6rp%20%231/data",
+			want:       "E:/Floorp/data",
+			ok:         true,
+		},
+		{
+			name:       "lowercase percent-encoding",
+			relocation: pathRelocation{Prev: `D:\My Apps`, Curr: `E:\Floorp`},
+			encoded:    "D:/My%20apps/x",
+			want:       "E:/Floorp/x",
+			ok:         true,
+		},
+		{
+			name:       "special characters are encoded",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\A & B #2 100%`},
+			encoded:    "D:/Floorp/data",
+			want:       "E:/A%20&%20B%20%232%20100%25/data",
+			ok:         true,
+		},
+		{
+			name:       "fragment and query",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\Floorp`},
+			encoded:    "D:/Floorp#top",
+			want:       "E:/Floorp#top",
+			ok:         true,
+		},
+		{
+			name:       "jar separator",
+			relocation: pathRelocation{Prev: `D:\Floorp\ext.xpi`, Curr: `E:\Floorp\ext.xpi`},
+			encoded:    "D:/Floorp/ext.xpi!/manifest.json",
+			want:       "E:/Floorp/ext.xpi!/manifest.json",
+			ok:         true,
+		},
+		{
+			name:       "prefix only",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\Floorp`},
+			encoded:    "D:/FloorpOld/data",
+			want:       "D:/FloorpOld/data",
+		},
+		{
+			name:       "encoded prefix only",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\Floorp`},
+			encoded:    "D:/Floorp%20Old/data",
+			want:       "D:/Floorp%20Old/data",
+		},
+		{
+			name:       "other drive",
+			relocation: pathRelocation{Prev: `D:\Floorp`, Curr: `E:\Floorp`},
+			encoded:    "C:/Floorp/data",
+			want:       "C:/Floorp/data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := relocateFileURLPath(tt.encoded, tt.relocation)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("relocateFileURLPath(%q) = %q, %v, want %q, %v", tt.encoded, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRelocatePath(t *testing.T) {
+	relocation := pathRelocation{Prev: `D:\Floorp\`, Curr: `E:\Apps\Floorp`}
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{`D:\Floorp\data`, `E:\Apps\Floorp\data`, true},
+		{`d:\FLOORP\data`, `E:\Apps\Floorp\data`, true},
+		{`D:/Floorp/data`, `E:/Apps/Floorp/data`, true},
+		{`D:\Floorp`, `E:\Apps\Floorp`, true},
+		{`D:\FloorpOld\data`, `D:\FloorpOld\data`, false},
+		{`D:\Floor`, `D:\Floor`, false},
+		{`C:\D:\Floorp`, `C:\D:\Floorp`, false},
+	}
+	for _, tt := range tests {
+		got, ok := relocatePath(tt.value, relocation)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("relocatePath(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRelocateText(t *testing.T) {
+	relocations := []pathRelocation{{Prev: `D:\Floorp`, Curr: `E:\Floorp`}}
+	content := "library=D:\\Floorp\\app\\softokn3.dll\r\nold=D:\\FloorpOld\\x\r\nunix=D:/Floorp/data\r\n"
+	want := "library=E:\\Floorp\\app\\softokn3.dll\r\nold=D:\\FloorpOld\\x\r\nunix=E:/Floorp/data\r\n"
+	if got, count := relocateText(content, relocations); got != want || count != 2 {
+		t.Errorf("relocateText() = %q (%d), want %q (2)", got, count, want)
+	}
+}
+
+func TestRelocateProfileOnce(t *testing.T) {
+	saved := *app
+	t.Cleanup(func() {
+		*app = saved
+	})
+	// The new location is inside the previous one, a second relocation would
+	// match the already relocated paths
+	app.Prev.RootPath, app.Prev.AppPath, app.Prev.DataPath = `D:\Floorp`, `D:\Floorp\app`, `D:\Floorp\data`
+	app.RootPath, app.AppPath, app.DataPath = `D:\Floorp\portable`, `D:\Floorp\portable\app`, `D:\Floorp\portable\data`
+
+	profile := t.TempDir()
+	pkcs11 := filepath.Join(profile, "pkcs11.txt")
+	writeFiles(t, map[string]string{pkcs11: `library=D:\Floorp\app\softokn3.dll`})
+
+	// Kiosk restarts prepare the profile again
+	for i := 0; i < 2; i++ {
+		if err := relocateProfile(profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := os.ReadFile(pkcs11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `library=D:\Floorp\portable\app\softokn3.dll`; string(raw) != want {
+		t.Errorf("pkcs11.txt = %q, want %q", raw, want)
+	}
+	if relocations := relocationPaths(); len(relocations) != 0 {
+		t.Errorf("relocationPaths() = %v after relocation, want none", relocations)
+	}
+}
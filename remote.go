@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// runningProfileFile returns the file recording the profile folder of the
+// launcher instance holding the mutex.
+func runningProfileFile() string {
+	return utl.PathJoin(app.DataPath, "running-profile.txt")
+}
+
+// writeRunningProfile records profileFolder as the profile of the running
+// instance, so later launches can tell whether they target the same profile.
+func writeRunningProfile(profileFolder string) {
+	if err := os.WriteFile(runningProfileFile(), []byte(profileFolder), 0644); err != nil {
+		log.Error().Err(err).Msgf("Cannot write %s", runningProfileFile())
+	}
+}
+
+// removeRunningProfile removes the record of the running instance profile.
+func removeRunningProfile() {
+	if err := os.Remove(runningProfileFile()); err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Msgf("Cannot remove %s", runningProfileFile())
+	}
+}
+
+// isRunningProfile reports whether the running instance uses profileFolder.
+// It is false when the running instance did not record its profile.
+func isRunningProfile(profileFolder string) bool {
+	raw, err := os.ReadFile(runningProfileFile())
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(filepath.Clean(strings.TrimSpace(string(raw))), filepath.Clean(profileFolder))
+}
+
+// forwardToRunningInstance hands the command-line arguments over to the Floorp
+// instance already running on profileFolder. Floorp detects the running instance
+// through its remote protocol (same profile path, no --no-remote) and opens the
+// URLs or files there, then the spawned process exits.
+func forwardToRunningInstance(profileFolder string, args []string) error {
+	if !utl.Exists(app.Process) {
+		return errors.Errorf("application not found in %s", app.Process)
+	}
+
+	remoteArgs := append([]string{"--profile", profileFolder}, args...)
+	log.Info().Msgf("Forwarding to running instance: %s %s", app.Process, strings.Join(remoteArgs, " "))
+
+	execute := exec.Command(app.Process, remoteArgs...)
+	execute.Dir = app.WorkingDir
+	if err := execute.Run(); err != nil {
+		return errors.Wrap(err, "remote command failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/mozlz4"
+	"github.com/Jeffail/gabs"
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+)
+
+// searchDisclaimer is salted into the verification hashes of search.json.
+const searchDisclaimer = "By modifying this file, I agree that I am doing so only within $appName itself, using official, user-driven search engine selection processes, and in a way which does not circumvent user consent. I acknowledge that any attempts to change this file from outside of $appName are a malicious act, and will be responded to accordingly."
+
+// searchHashedFields maps the metaData fields of search.json holding an engine
+// to the field holding their verification hash. The last two are used by
+// older releases.
+var searchHashedFields = map[string]string{
+	"defaultEngineId":        "defaultEngineIdHash",
+	"privateDefaultEngineId": "privateDefaultEngineIdHash",
+	"current":                "hash",
+	"private":                "privateHash",
+}
+
+// searchConfig holds the search engines settings.
+type searchConfig struct {
+	RepairHash bool                 `yaml:"repair_hash" mapstructure:"repair_hash"`
+	Default    string               `yaml:"default" mapstructure:"default"`
+	Engines    []searchEngineConfig `yaml:"engines" mapstructure:"engines"`
+}
+
+// searchEngineConfig is an organisation-defined search engine.
+type searchEngineConfig struct {
+	Name        string `yaml:"name" mapstructure:"name"`
+	URL         string `yaml:"url" mapstructure:"url"`
+	SuggestURL  string `yaml:"suggest_url" mapstructure:"suggest_url"`
+	IconURL     string `yaml:"icon_url" mapstructure:"icon_url"`
+	Alias       string `yaml:"alias" mapstructure:"alias"`
+	Description string `yaml:"description" mapstructure:"description"`
+	Method      string `yaml:"method" mapstructure:"method"`
+	PostData    string `yaml:"post_data" mapstructure:"post_data"`
+}
+
+// searchPolicies returns the SearchEngines policy adding the configured
+// engines and default engine.
+func searchPolicies(search searchConfig) map[string]interface{} {
+	searchEngines := map[string]interface{}{}
+
+	if len(search.Engines) > 0 {
+		engines := make([]interface{}, 0, len(search.Engines))
+		for _, engine := range search.Engines {
+			policy := map[string]interface{}{
+				"Name":        engine.Name,
+				"URLTemplate": engine.URL,
+			}
+			optional := map[string]string{
+				"SuggestURLTemplate": engine.SuggestURL,
+				"IconURL":            engine.IconURL,
+				"Alias":              engine.Alias,
+				"Description":        engine.Description,
+				"Method":             strings.ToUpper(engine.Method),
+				"PostData":           engine.PostData,
+			}
+			for key, value := range optional {
+				if value != "" {
+					policy[key] = value
+				}
+			}
+			engines = append(engines, policy)
+		}
+		searchEngines["Add"] = engines
+	}
+	if search.Default != "" {
+		searchEngines["Default"] = search.Default
+	}
+
+	if len(searchEngines) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"SearchEngines": searchEngines}
+}
+
+// updateSearchSettings repairs the verification hashes of search.json.mozlz4
+// for the current profile and selects the configured default engine.
+func updateSearchSettings(profileFolder string) error {
+	searchFile := filepath.Join(profileFolder, "search.json.mozlz4")
+	if !utl.Exists(searchFile) || (!cfg.Search.RepairHash && cfg.Search.Default == "") {
+		return nil
+	}
+
+	raw, err := mozlz4.ReadFile(searchFile)
+	if err != nil {
+		return errors.Wrap(err, "Cannot read search settings")
+	}
+	settings, err := gabs.ParseJSON(raw)
+	if err != nil {
+		return errors.Wrap(err, "Cannot parse search settings")
+	}
+
+	changed := false
+	if cfg.Search.Default != "" {
+		changed = selectDefaultSearchEngine(settings, cfg.Search.Default)
+	}
+
+	// Hashes are salted with the profile folder name, they must be recomputed
+	// when a default engine was selected or the profile was renamed.
+	if cfg.Search.RepairHash || changed {
+		appName := applicationName()
+		for field, hashField := range searchHashedFields {
+			engine, ok := settings.Path("metaData." + field).Data().(string)
+			if !ok || engine == "" {
+				continue
+			}
+			hash := searchVerificationHash(profileFolder, engine, appName)
+			if current, _ := settings.Path("metaData." + hashField).Data().(string); current != hash {
+				log.Info().Msgf("Repairing search engine hash of %s", field)
+				if _, err := settings.SetP(hash, "metaData."+hashField); err != nil {
+					return errors.Wrapf(err, "Cannot set %s", hashField)
+				}
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return mozlz4.WriteFile(searchFile, settings.Bytes(), 0644)
+}
+
+// selectDefaultSearchEngine sets the default engine of search settings to the
+// engine with the given name, if installed.
+func selectDefaultSearchEngine(settings *gabs.Container, name string) bool {
+	engines, err := settings.S("engines").Children()
+	if err != nil {
+		return false
+	}
+
+	for _, engine := range engines {
+		engineName, _ := engine.S("_name").Data().(string)
+		if !strings.EqualFold(engineName, name) {
+			continue
+		}
+
+		// Releases with engine ids store the id, older ones the name
+		field, value := "current", engineName
+		if id, ok := engine.S("id").Data().(string); ok && id != "" {
+			field, value = "defaultEngineId", id
+		}
+		if current, _ := settings.Path("metaData." + field).Data().(string); current == value {
+			return false
+		}
+
+		log.Info().Msgf("Setting default search engine to %s", engineName)
+		if _, err := settings.SetP(value, "metaData."+field); err != nil {
+			log.Error().Err(err).Msg("Cannot set default search engine")
+			return false
+		}
+		return true
+	}
+
+	log.Warn().Msgf("Search engine %s not installed yet, it will be selected on next launch", name)
+	return false
+}
+
+// searchVerificationHash returns the hash Floorp uses to check an engine
+// stored in search.json was not set from outside the browser.
+func searchVerificationHash(profileFolder string, engine string, appName string) string {
+	salt := filepath.Base(profileFolder) + engine + strings.Replace(searchDisclaimer, "$appName", appName, -1)
+	sum := sha256.Sum256([]byte(salt))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// applicationName returns the application name declared in application.ini.
+func applicationName() string {
+	if name := applicationInfo("Name"); name != "" {
+		return name
+	}
+	return app.Name
+}
+
+// applicationInfo returns a key of the [App] section of application.ini, or
+// an empty string if it cannot be read.
+func applicationInfo(key string) string {
+	raw, err := os.ReadFile(filepath.Join(app.AppPath, "application.ini"))
+	if err != nil {
+		return ""
+	}
+	section := ""
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		if section == "[App]" && strings.HasPrefix(line, key+"=") {
+			return strings.TrimPrefix(line, key+"=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/gabs"
+)
+
+func TestSearchVerificationHash(t *testing.T) {
+	// Reference values computed independently, following
+	// SearchUtils.getVerificationHash of Firefox: base64(sha256(profile folder
+	// name + engine + disclaimer with $appName replaced))
+	tests := []struct {
+		profile string
+		engine  string
+		appName string
+		want    string
+	}{
+		{"abcd1234.default-release", "google", "Firefox", "m5ln3hijtJe6SfPIRcyvbKY1H6bkNBieZRE6RykLODQ="},
+		{"default", "ddg", "Floorp", "GKgZWWG2OIr2cCMdGocqHah9UtRDjTwG0FBSE1JQTE4="},
+		{"work", "Intranet", "Floorp", "Q1EsU9ZoWF7OLFFAwDfB1nFlZrKf3jktWRsE254v1VU="},
+	}
+	for _, tt := range tests {
+		// Only the name of the profile folder is salted in
+		profileFolder := filepath.Join(t.TempDir(), "profile", tt.profile)
+		if got := searchVerificationHash(profileFolder, tt.engine, tt.appName); got != tt.want {
+			t.Errorf("searchVerificationHash(%s, %s, %s) = %s, want %s", tt.profile, tt.engine, tt.appName, got, tt.want)
+		}
+	}
+}
+
+func TestSelectDefaultSearchEngine(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    string
+		engine      string
+		wantChanged bool
+		wantField   string
+		wantValue   string
+	}{
+		{
+			name:        "engine id",
+			settings:    `{"engines": [{"_name": "Google", "id": "google"}, {"_name": "Intranet", "id": "intranet-id"}], "metaData": {"defaultEngineId": "google"}}`,
+			engine:      "intranet",
+			wantChanged: true,
+			wantField:   "defaultEngineId",
+			wantValue:   "intranet-id",
+		},
+		{
+			name:        "engine name of older releases",
+			settings:    `{"engines": [{"_name": "Google"}, {"_name": "Intranet"}], "metaData": {"current": "Google"}}`,
+			engine:      "Intranet",
+			wantChanged: true,
+			wantField:   "current",
+			wantValue:   "Intranet",
+		},
+		{
+			name:      "already selected",
+			settings:  `{"engines": [{"_name": "Intranet", "id": "intranet-id"}], "metaData": {"defaultEngineId": "intranet-id"}}`,
+			engine:    "Intranet",
+			wantField: "defaultEngineId",
+			wantValue: "intranet-id",
+		},
+		{
+			name:      "not installed",
+			settings:  `{"engines": [{"_name": "Google", "id": "google"}], "metaData": {"defaultEngineId": "google"}}`,
+			engine:    "Intranet",
+			wantField: "defaultEngineId",
+			wantValue: "google",
+		},
+		{
+			name:     "no engines",
+			settings: `{"metaData": {}}`,
+			engine:   "Intranet",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := gabs.ParseJSON([]byte(tt.settings))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := selectDefaultSearchEngine(settings, tt.engine); got != tt.wantChanged {
+				t.Errorf("selectDefaultSearchEngine() = %v, want %v", got, tt.wantChanged)
+			}
+			if tt.wantField == "" {
+				return
+			}
+			if got, _ := settings.Path("metaData." + tt.wantField).Data().(string); got != tt.wantValue {
+				t.Errorf("metaData.%s = %q, want %q", tt.wantField, got, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestSearchPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		search searchConfig
+		want   map[string]interface{}
+	}{
+		{
+			name:   "nothing configured",
+			search: searchConfig{RepairHash: true},
+		},
+		{
+			name:   "default only",
+			search: searchConfig{Default: "DuckDuckGo"},
+			want: map[string]interface{}{
+				"SearchEngines": map[string]interface{}{"Default": "DuckDuckGo"},
+			},
+		},
+		{
+			name: "engines",
+			search: searchConfig{
+				Default: "Intranet",
+				Engines: []searchEngineConfig{
+					{
+						Name:       "Intranet",
+						URL:        "https://intranet.example.com/search?q={searchTerms}",
+						SuggestURL: "https://intranet.example.com/suggest?q={searchTerms}",
+						Alias:      "@intra",
+						Method:     "post",
+						PostData:   "q={searchTerms}",
+					},
+					{Name: "Wiki", URL: "https://wiki.example.com/?search={searchTerms}"},
+				},
+			},
+			want: map[string]interface{}{
+				"SearchEngines": map[string]interface{}{
+					"Default": "Intranet",
+					"Add": []interface{}{
+						map[string]interface{}{
+							"Name":               "Intranet",
+							"URLTemplate":        "https://intranet.example.com/search?q={searchTerms}",
+							"SuggestURLTemplate": "https://intranet.example.com/suggest?q={searchTerms}",
+							"Alias":              "@intra",
+							"Method":             "POST",
+							"PostData":           "q={searchTerms}",
+						},
+						map[string]interface{}{
+							"Name":        "Wiki",
+							"URLTemplate": "https://wiki.example.com/?search={searchTerms}",
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchPolicies(tt.search); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchPolicies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
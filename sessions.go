@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/sessionstore"
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+)
+
+// listSessions prints the session snapshots of the profile to stdout.
+func listSessions(profileFolder string) error {
+	snapshots, err := sessionstore.List(profileFolder)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Fprintf(os.Stdout, "No session found in %s\n", profileFolder)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tLAST UPDATE\tWINDOWS\tTABS")
+	for _, snapshot := range snapshots {
+		if snapshot.Err != nil {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t(%v)\n", snapshot.Name, snapshot.ModTime.Format("2006-01-02 15:04:05"), snapshot.Err)
+			continue
+		}
+		lastUpdate := snapshot.LastUpdate
+		if lastUpdate.IsZero() {
+			lastUpdate = snapshot.ModTime
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", snapshot.Name, lastUpdate.Format("2006-01-02 15:04:05"), snapshot.Windows, snapshot.Tabs)
+	}
+
+	return w.Flush()
+}
+
+// exportSession writes the tabs of a session snapshot to output (stdout if
+// empty) in the given format. The format defaults to the output extension or
+// HTML.
+func exportSession(profileFolder string, name string, format string, output string) error {
+	snapshot, err := sessionstore.Find(profileFolder, name)
+	if err != nil {
+		return err
+	}
+	session, err := sessionstore.Load(snapshot.Path)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot read %s", snapshot.Name)
+	}
+
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(output), ".")
+	}
+	if format == "" {
+		format = sessionstore.FormatHTML
+	}
+	if format, err = sessionstore.ParseFormat(format); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	tabs := session.Tabs()
+	if err := sessionstore.Export(w, tabs, format); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Exported %d tab(s) from %s", len(tabs), snapshot.Name)
+	return nil
+}
+
+// restoreSession makes a session snapshot the one restored on this launch.
+func restoreSession(profileFolder string, name string) error {
+	snapshot, err := sessionstore.Find(profileFolder, name)
+	if err != nil {
+		return err
+	}
+
+	backup, err := sessionstore.Promote(profileFolder, snapshot)
+	if err != nil {
+		return err
+	}
+	if backup != "" {
+		log.Info().Msgf("Previous session saved to %s", backup)
+	}
+	log.Info().Msgf("Session %s (%d window(s), %d tab(s)) will be restored", snapshot.Name, snapshot.Windows, snapshot.Tabs)
+
+	// Restore the session once, whatever the startup page
+	launchPrefs = append(launchPrefs, mozillaPref{
+		Func:  prefFuncPref,
+		Name:  "browser.sessionstore.resume_session_once",
+		Value: "true",
+	})
+
+	return nil
+}
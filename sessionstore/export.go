@@ -0,0 +1,103 @@
+package sessionstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Export formats.
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// ParseFormat returns the export format named format, which is either a
+// format or a file extension (htm, md).
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatHTML, "htm":
+		return FormatHTML, nil
+	case FormatMarkdown, "md":
+		return FormatMarkdown, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", errors.Errorf("unknown export format %s", format)
+	}
+}
+
+// Export writes tabs to w in the given format.
+func Export(w io.Writer, tabs []Tab, format string) error {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatHTML:
+		return exportHTML(w, tabs)
+	case FormatMarkdown:
+		return exportMarkdown(w, tabs)
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tabs)
+	}
+}
+
+func exportHTML(w io.Writer, tabs []Tab) error {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Session</title>\n</head>\n<body>\n")
+	window := 0
+	for _, tab := range tabs {
+		if tab.Window != window {
+			if window != 0 {
+				b.WriteString("</ul>\n")
+			}
+			window = tab.Window
+			fmt.Fprintf(&b, "<h2>Window %d</h2>\n<ul>\n", window)
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(tab.URL), html.EscapeString(tabTitle(tab)))
+	}
+	if window != 0 {
+		b.WriteString("</ul>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func exportMarkdown(w io.Writer, tabs []Tab) error {
+	var b strings.Builder
+	window := 0
+	for _, tab := range tabs {
+		if tab.Window != window {
+			if window != 0 {
+				b.WriteString("\n")
+			}
+			window = tab.Window
+			fmt.Fprintf(&b, "## Window %d\n\n", window)
+		}
+		title := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`).Replace(tabTitle(tab))
+		url := strings.NewReplacer(`(`, `%28`, `)`, `%29`, ` `, `%20`, `<`, `%3C`, `>`, `%3E`).Replace(tab.URL)
+		fmt.Fprintf(&b, "- [%s](%s)\n", title, url)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func tabTitle(tab Tab) string {
+	if tab.Title != "" {
+		return tab.Title
+	}
+	return tab.URL
+}
@@ -0,0 +1,74 @@
+package sessionstore
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	tabs := []Tab{
+		{Window: 1, Title: `<b>"Tom" & Jerry</b>`, URL: `https://example.com/?a=1&b="2"`},
+		{Window: 1, URL: "https://example.com/no-title"},
+		{Window: 2, Title: `[link] \ text`, URL: "https://example.com/a (b)"},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: FormatHTML,
+			want: "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Session</title>\n</head>\n<body>\n" +
+				"<h2>Window 1</h2>\n<ul>\n" +
+				"<li><a href=\"https://example.com/?a=1&amp;b=&#34;2&#34;\">&lt;b&gt;&#34;Tom&#34; &amp; Jerry&lt;/b&gt;</a></li>\n" +
+				"<li><a href=\"https://example.com/no-title\">https://example.com/no-title</a></li>\n" +
+				"</ul>\n<h2>Window 2</h2>\n<ul>\n" +
+				"<li><a href=\"https://example.com/a (b)\">[link] \\ text</a></li>\n" +
+				"</ul>\n</body>\n</html>\n",
+		},
+		{
+			format: "md",
+			want: "## Window 1\n\n" +
+				"- [\\<b\\>\"Tom\" & Jerry\\</b\\>](https://example.com/?a=1&b=\"2\")\n" +
+				"- [https://example.com/no-title](https://example.com/no-title)\n" +
+				"\n## Window 2\n\n" +
+				"- [\\[link\\] \\\\ text](https://example.com/a%20%28b%29)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Export(&out, tabs, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Export() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "html", want: FormatHTML},
+		{format: "HTM", want: FormatHTML},
+		{format: "markdown", want: FormatMarkdown},
+		{format: "md", want: FormatMarkdown},
+		{format: "json", want: FormatJSON},
+		{format: "txt", wantErr: true},
+		{format: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
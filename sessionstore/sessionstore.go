@@ -0,0 +1,254 @@
+// Package sessionstore reads the session snapshots of a Floorp profile
+// (sessionstore.jsonlz4 and the files of sessionstore-backups) to list,
+// export and restore them.
+package sessionstore
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/mozlz4"
+	"github.com/pkg/errors"
+)
+
+// CurrentFile is the session store file read by Floorp on startup.
+const CurrentFile = "sessionstore.jsonlz4"
+
+// snapshotPatterns are the session files of a profile, relative to the
+// profile folder.
+var snapshotPatterns = []string{
+	CurrentFile,
+	"sessionstore-backups/recovery.jsonlz4",
+	"sessionstore-backups/recovery.baklz4",
+	"sessionstore-backups/previous.jsonlz4",
+	"sessionstore-backups/upgrade.jsonlz4-*",
+}
+
+// Session is the content of a session file.
+type Session struct {
+	Windows       []Window `json:"windows"`
+	ClosedWindows []Window `json:"_closedWindows"`
+	Session       struct {
+		LastUpdate int64 `json:"lastUpdate"`
+		StartTime  int64 `json:"startTime"`
+	} `json:"session"`
+}
+
+// Window is a browser window of a session.
+type Window struct {
+	Tabs []WindowTab `json:"tabs"`
+}
+
+// WindowTab is a tab of a window with its navigation history.
+type WindowTab struct {
+	Entries      []Entry `json:"entries"`
+	Index        int     `json:"index"`
+	Pinned       bool    `json:"pinned"`
+	LastAccessed int64   `json:"lastAccessed"`
+}
+
+// Entry is a history entry of a tab.
+type Entry struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// Tab is the current page of a tab.
+type Tab struct {
+	Window       int       `json:"window"`
+	Title        string    `json:"title"`
+	URL          string    `json:"url"`
+	Pinned       bool      `json:"pinned"`
+	LastAccessed time.Time `json:"lastAccessed,omitzero"`
+}
+
+// Snapshot describes a session file of a profile.
+type Snapshot struct {
+	Name       string
+	Path       string
+	ModTime    time.Time
+	LastUpdate time.Time
+	Windows    int
+	Tabs       int
+	Err        error
+}
+
+// List returns the session snapshots of a profile, most recent first.
+// Snapshots that cannot be read are returned with Err set.
+func List(profileFolder string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	for _, pattern := range snapshotPatterns {
+		matches, err := filepath.Glob(filepath.Join(profileFolder, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			snapshots = append(snapshots, describe(profileFolder, match))
+		}
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].timestamp().After(snapshots[j].timestamp())
+	})
+	return snapshots, nil
+}
+
+func describe(profileFolder string, path string) Snapshot {
+	name, err := filepath.Rel(profileFolder, path)
+	if err != nil {
+		name = path
+	}
+	snapshot := Snapshot{Name: filepath.ToSlash(name), Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		snapshot.Err = err
+		return snapshot
+	}
+	snapshot.ModTime = info.ModTime()
+
+	session, err := Load(path)
+	if err != nil {
+		snapshot.Err = err
+		return snapshot
+	}
+	if session.Session.LastUpdate > 0 {
+		snapshot.LastUpdate = time.UnixMilli(session.Session.LastUpdate)
+	}
+	snapshot.Windows = len(session.Windows)
+	snapshot.Tabs = len(session.Tabs())
+
+	return snapshot
+}
+
+// timestamp returns the last update time of the session, or the file
+// modification time if unknown.
+func (s Snapshot) timestamp() time.Time {
+	if !s.LastUpdate.IsZero() {
+		return s.LastUpdate
+	}
+	return s.ModTime
+}
+
+// Find returns the snapshot of a profile matching name, either its path
+// relative to the profile folder or its base name.
+func Find(profileFolder string, name string) (Snapshot, error) {
+	snapshots, err := List(profileFolder)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	name = filepath.ToSlash(name)
+	for _, snapshot := range snapshots {
+		if strings.EqualFold(snapshot.Name, name) || strings.EqualFold(filepath.Base(snapshot.Path), name) {
+			return snapshot, nil
+		}
+	}
+
+	return Snapshot{}, errors.Errorf("session snapshot %s not found", name)
+}
+
+// Load reads a session file.
+func Load(path string) (*Session, error) {
+	raw, err := mozlz4.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return nil, errors.Wrap(err, "invalid session content")
+	}
+
+	return &session, nil
+}
+
+// Tabs returns the current page of each tab of the open windows.
+func (s *Session) Tabs() []Tab {
+	var tabs []Tab
+	for i, window := range s.Windows {
+		for _, tab := range window.Tabs {
+			if len(tab.Entries) == 0 {
+				continue
+			}
+
+			// index is 1-based and points to the current history entry
+			index := tab.Index - 1
+			if index < 0 || index >= len(tab.Entries) {
+				index = len(tab.Entries) - 1
+			}
+			entry := tab.Entries[index]
+
+			var lastAccessed time.Time
+			if tab.LastAccessed > 0 {
+				lastAccessed = time.UnixMilli(tab.LastAccessed)
+			}
+			tabs = append(tabs, Tab{
+				Window:       i + 1,
+				Title:        entry.Title,
+				URL:          entry.URL,
+				Pinned:       tab.Pinned,
+				LastAccessed: lastAccessed,
+			})
+		}
+	}
+	return tabs
+}
+
+// Promote makes a snapshot the session restored on next startup. The current
+// session file, if any, is kept next to it with a timestamp suffix.
+func Promote(profileFolder string, snapshot Snapshot) (string, error) {
+	current := filepath.Join(profileFolder, CurrentFile)
+	if samePath(snapshot.Path, current) {
+		return "", nil
+	}
+
+	// Make sure the snapshot is usable before replacing anything
+	if _, err := Load(snapshot.Path); err != nil {
+		return "", errors.Wrapf(err, "cannot read %s", snapshot.Name)
+	}
+
+	var backup string
+	if _, err := os.Stat(current); err == nil {
+		backup = current + "." + time.Now().Format("20060102-150405") + ".bak"
+		if err := os.Rename(current, backup); err != nil {
+			return "", errors.Wrap(err, "cannot backup current session")
+		}
+	}
+
+	if err := copyFile(snapshot.Path, current); err != nil {
+		if backup != "" {
+			_ = os.Rename(backup, current)
+		}
+		return "", errors.Wrapf(err, "cannot promote %s", snapshot.Name)
+	}
+
+	return backup, nil
+}
+
+func samePath(a, b string) bool {
+	return strings.EqualFold(filepath.Clean(a), filepath.Clean(b))
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,177 @@
+package sessionstore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/mozlz4"
+)
+
+func TestTabs(t *testing.T) {
+	tests := []struct {
+		name    string
+		session string
+		want    []Tab
+	}{
+		{
+			name: "index selects the current entry",
+			session: `{"windows": [{"selected": 2, "tabs": [
+				{"index": 1, "entries": [{"url": "https://a.example/", "title": "A"}, {"url": "https://b.example/", "title": "B"}]},
+				{"index": 2, "entries": [{"url": "https://c.example/", "title": "C"}, {"url": "https://d.example/", "title": "D"}]}
+			]}]}`,
+			want: []Tab{
+				{Window: 1, Title: "A", URL: "https://a.example/"},
+				{Window: 1, Title: "D", URL: "https://d.example/"},
+			},
+		},
+		{
+			name: "index out of range falls back to the last entry",
+			session: `{"windows": [{"tabs": [
+				{"index": 0, "entries": [{"url": "https://a.example/"}, {"url": "https://b.example/"}]},
+				{"index": 5, "entries": [{"url": "https://c.example/"}, {"url": "https://d.example/"}]}
+			]}]}`,
+			want: []Tab{
+				{Window: 1, URL: "https://b.example/"},
+				{Window: 1, URL: "https://d.example/"},
+			},
+		},
+		{
+			name: "tabs without entries are skipped",
+			session: `{"windows": [{"tabs": [
+				{"index": 1, "entries": []},
+				{"index": 1, "pinned": true, "lastAccessed": 1700000000000, "entries": [{"url": "https://a.example/", "title": "A"}]}
+			]}]}`,
+			want: []Tab{
+				{Window: 1, Title: "A", URL: "https://a.example/", Pinned: true, LastAccessed: time.UnixMilli(1700000000000)},
+			},
+		},
+		{
+			name: "closed windows are left out",
+			session: `{
+				"windows": [
+					{"tabs": [{"index": 1, "entries": [{"url": "https://a.example/"}]}]},
+					{"tabs": [{"index": 1, "entries": [{"url": "https://b.example/"}]}]}
+				],
+				"_closedWindows": [
+					{"tabs": [{"index": 1, "entries": [{"url": "https://closed.example/"}]}]}
+				]
+			}`,
+			want: []Tab{
+				{Window: 1, URL: "https://a.example/"},
+				{Window: 2, URL: "https://b.example/"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var session Session
+			if err := json.Unmarshal([]byte(tt.session), &session); err != nil {
+				t.Fatal(err)
+			}
+			if got := session.Tabs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tabs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeSession writes a session file holding a single tab opened on url.
+func writeSession(t *testing.T, path string, url string) {
+	t.Helper()
+	raw := []byte(`{"windows": [{"tabs": [{"index": 1, "entries": [{"url": "` + url + `"}]}]}]}`)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := mozlz4.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadURL(t *testing.T, path string) string {
+	t.Helper()
+	session, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabs := session.Tabs()
+	if len(tabs) != 1 {
+		t.Fatalf("%s has %d tabs, want 1", path, len(tabs))
+	}
+	return tabs[0].URL
+}
+
+func TestPromote(t *testing.T) {
+	profile := t.TempDir()
+	current := filepath.Join(profile, CurrentFile)
+	previous := filepath.Join(profile, "sessionstore-backups", "previous.jsonlz4")
+	writeSession(t, current, "https://current.example/")
+	writeSession(t, previous, "https://previous.example/")
+
+	snapshot, err := Find(profile, "previous.jsonlz4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := Promote(profile, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loadURL(t, current); got != "https://previous.example/" {
+		t.Errorf("current session opens %s, want the promoted snapshot", got)
+	}
+	if backup == "" {
+		t.Fatal("Promote() returned no backup")
+	}
+	if got := loadURL(t, backup); got != "https://current.example/" {
+		t.Errorf("backup opens %s, want the previous current session", got)
+	}
+	if got := loadURL(t, previous); got != "https://previous.example/" {
+		t.Errorf("snapshot opens %s, it must be left untouched", got)
+	}
+}
+
+func TestPromoteCurrent(t *testing.T) {
+	profile := t.TempDir()
+	writeSession(t, filepath.Join(profile, CurrentFile), "https://current.example/")
+
+	snapshot, err := Find(profile, CurrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := Promote(profile, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != "" {
+		t.Errorf("Promote() of the current session made backup %s", backup)
+	}
+}
+
+func TestPromoteInvalidSnapshot(t *testing.T) {
+	profile := t.TempDir()
+	current := filepath.Join(profile, CurrentFile)
+	writeSession(t, current, "https://current.example/")
+	broken := filepath.Join(profile, "sessionstore-backups", "recovery.jsonlz4")
+	if err := os.MkdirAll(filepath.Dir(broken), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(broken, []byte("not a session"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := Snapshot{Name: "sessionstore-backups/recovery.jsonlz4", Path: broken}
+	if _, err := Promote(profile, snapshot); err == nil {
+		t.Fatal("Promote() accepted an unreadable snapshot")
+	}
+	if got := loadURL(t, current); got != "https://current.example/" {
+		t.Errorf("current session opens %s, it must be left untouched", got)
+	}
+	matches, _ := filepath.Glob(current + ".*.bak")
+	if len(matches) != 0 {
+		t.Errorf("Promote() left backups %v", matches)
+	}
+}
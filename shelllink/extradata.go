@@ -0,0 +1,125 @@
+package shelllink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// Extra data block signatures.
+const (
+	environmentVariableDataBlock = 0xA0000001
+	propertyStoreDataBlock       = 0xA0000009
+)
+
+// Serialized property storage constants [MS-PROPSTORE].
+const (
+	propertyStorageVersion = 0x53505331 // "1SPS"
+	vtLPWSTR               = 0x001F
+)
+
+// propertyKey identifies a property of a property store.
+type propertyKey struct {
+	FormatID [16]byte
+	ID       uint32
+}
+
+// appUserModelIDKey is PKEY_AppUserModel_ID,
+// {9F4C2855-9F79-4B39-A8D0-E1D42DE1D5F3} 5.
+var appUserModelIDKey = propertyKey{
+	FormatID: [16]byte{0x55, 0x28, 0x4C, 0x9F, 0x79, 0x9F, 0x39, 0x4B, 0xA8, 0xD0, 0xE1, 0xD4, 0x2D, 0xE1, 0xD5, 0xF3},
+	ID:       5,
+}
+
+// unmarshalExtraData reads the fields of a known extra data block.
+func (l *Link) unmarshalExtraData(block []byte, flags uint32) {
+	switch binary.LittleEndian.Uint32(block[4:]) {
+	case environmentVariableDataBlock:
+		// TargetUnicode, used when the link has no LinkInfo
+		if flags&hasExpString != 0 && l.Target == "" && len(block) >= 788 {
+			l.Target = trimNull(decodeUTF16(block[268:788]))
+		}
+	case propertyStoreDataBlock:
+		if value, ok := lookupStringProperty(block[8:], appUserModelIDKey); ok {
+			l.AppUserModelID = value
+		}
+	}
+}
+
+// marshalPropertyStoreBlock returns a property store data block holding a
+// single string property.
+func marshalPropertyStoreBlock(key propertyKey, value string) []byte {
+	chars := utf16.Encode([]rune(value + "\x00"))
+	padded := (len(chars)*2 + 3) &^ 3
+
+	var propertyValue bytes.Buffer
+	writeUint32(&propertyValue, uint32(9+8+padded)) // ValueSize
+	writeUint32(&propertyValue, key.ID)
+	propertyValue.WriteByte(0) // Reserved
+	writeUint16(&propertyValue, vtLPWSTR)
+	writeUint16(&propertyValue, 0) // Padding
+	writeUint32(&propertyValue, uint32(len(chars)))
+	writeUTF16(&propertyValue, chars)
+	propertyValue.Write(make([]byte, padded-len(chars)*2))
+
+	var storage bytes.Buffer
+	writeUint32(&storage, uint32(4+4+16+propertyValue.Len()+4)) // StorageSize
+	writeUint32(&storage, propertyStorageVersion)
+	storage.Write(key.FormatID[:])
+	storage.Write(propertyValue.Bytes())
+	writeUint32(&storage, 0) // Last value
+
+	var block bytes.Buffer
+	writeUint32(&block, uint32(8+storage.Len()+4)) // BlockSize
+	writeUint32(&block, propertyStoreDataBlock)
+	block.Write(storage.Bytes())
+	writeUint32(&block, 0) // Last storage
+
+	return block.Bytes()
+}
+
+// lookupStringProperty returns the value of a string property of a property
+// store.
+func lookupStringProperty(store []byte, key propertyKey) (string, bool) {
+	for len(store) >= 24 {
+		storageSize := int(binary.LittleEndian.Uint32(store))
+		if storageSize < 24 || storageSize > len(store) {
+			return "", false
+		}
+		storage := store[:storageSize]
+		store = store[storageSize:]
+
+		if binary.LittleEndian.Uint32(storage[4:]) != propertyStorageVersion || !bytes.Equal(storage[8:24], key.FormatID[:]) {
+			continue
+		}
+
+		values := storage[24:]
+		for len(values) >= 4 {
+			valueSize := int(binary.LittleEndian.Uint32(values))
+			if valueSize < 17 || valueSize > len(values) {
+				break
+			}
+			value := values[:valueSize]
+			values = values[valueSize:]
+
+			if binary.LittleEndian.Uint32(value[4:]) != key.ID || binary.LittleEndian.Uint16(value[9:]) != vtLPWSTR {
+				continue
+			}
+			count := int(binary.LittleEndian.Uint32(value[13:]))
+			if 17+count*2 > len(value) {
+				return "", false
+			}
+			s := decodeUTF16(value[17 : 17+count*2])
+			return trimNull(s), true
+		}
+	}
+	return "", false
+}
+
+func trimNull(s string) string {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
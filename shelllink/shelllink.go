@@ -0,0 +1,428 @@
+// Package shelllink reads and writes Windows shortcuts (.lnk files) following
+// the Shell Link Binary File Format [MS-SHLLINK]. It has no dependency on the
+// Windows shell, so shortcuts can be built and inspected on any platform.
+//
+// Links are written with a LinkInfo structure holding the local path of the
+// target, which the shell resolves without a target ID list.
+package shelllink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// ShowCommand is the window state of the target when launched.
+type ShowCommand uint32
+
+// Show commands.
+const (
+	ShowNormal      ShowCommand = 1
+	ShowMaximized   ShowCommand = 3
+	ShowMinNoActive ShowCommand = 7
+)
+
+// Link is a shell link.
+type Link struct {
+	Target         string
+	Arguments      string
+	WorkingDir     string
+	Description    string
+	RelativePath   string
+	IconLocation   string
+	IconIndex      int32
+	ShowCommand    ShowCommand
+	AppUserModelID string
+}
+
+// Errors returned when reading a link.
+var (
+	ErrInvalidHeader = errors.New("shelllink: invalid header")
+	ErrTruncated     = errors.New("shelllink: truncated data")
+)
+
+const headerSize = 0x4C
+
+// linkCLSID is 00021401-0000-0000-C000-000000000046.
+var linkCLSID = [16]byte{0x01, 0x14, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+
+// Link flags.
+const (
+	hasLinkTargetIDList = 1 << 0
+	hasLinkInfo         = 1 << 1
+	hasName             = 1 << 2
+	hasRelativePath     = 1 << 3
+	hasWorkingDir       = 1 << 4
+	hasArguments        = 1 << 5
+	hasIconLocation     = 1 << 6
+	isUnicode           = 1 << 7
+	hasExpString        = 1 << 9
+)
+
+// LinkInfo flags and sizes.
+const (
+	volumeIDAndLocalBasePath = 1 << 0
+	linkInfoHeaderSize       = 0x24
+	volumeIDHeaderSize       = 0x10
+	driveFixed               = 3
+)
+
+// header is the ShellLinkHeader structure.
+type header struct {
+	HeaderSize     uint32
+	LinkCLSID      [16]byte
+	LinkFlags      uint32
+	FileAttributes uint32
+	CreationTime   uint64
+	AccessTime     uint64
+	WriteTime      uint64
+	FileSize       uint32
+	IconIndex      int32
+	ShowCommand    uint32
+	HotKey         uint16
+	Reserved1      uint16
+	Reserved2      uint32
+	Reserved3      uint32
+}
+
+// MarshalBinary encodes the link.
+func (l *Link) MarshalBinary() ([]byte, error) {
+	h := header{
+		HeaderSize:  headerSize,
+		LinkCLSID:   linkCLSID,
+		LinkFlags:   isUnicode,
+		IconIndex:   l.IconIndex,
+		ShowCommand: uint32(l.ShowCommand),
+	}
+	if h.ShowCommand == 0 {
+		h.ShowCommand = uint32(ShowNormal)
+	}
+	if l.Target != "" {
+		h.LinkFlags |= hasLinkInfo
+	}
+
+	strings := []struct {
+		flag  uint32
+		value string
+	}{
+		{hasName, l.Description},
+		{hasRelativePath, l.RelativePath},
+		{hasWorkingDir, l.WorkingDir},
+		{hasArguments, l.Arguments},
+		{hasIconLocation, l.IconLocation},
+	}
+	for _, s := range strings {
+		if s.value != "" {
+			h.LinkFlags |= s.flag
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, h); err != nil {
+		return nil, err
+	}
+	if l.Target != "" {
+		buf.Write(marshalLinkInfo(l.Target))
+	}
+	for _, s := range strings {
+		if s.value == "" {
+			continue
+		}
+		chars := utf16.Encode([]rune(s.value))
+		if len(chars) > 0xFFFF {
+			return nil, errors.Errorf("shelllink: string too long (%d characters)", len(chars))
+		}
+		writeUint16(&buf, uint16(len(chars)))
+		writeUTF16(&buf, chars)
+	}
+	if l.AppUserModelID != "" {
+		buf.Write(marshalPropertyStoreBlock(appUserModelIDKey, l.AppUserModelID))
+	}
+	// Terminal block
+	writeUint32(&buf, 0)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a link.
+func (l *Link) UnmarshalBinary(data []byte) error {
+	var h header
+	if len(data) < headerSize {
+		return ErrInvalidHeader
+	}
+	if err := binary.Read(bytes.NewReader(data[:headerSize]), binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	if h.HeaderSize != headerSize || h.LinkCLSID != linkCLSID {
+		return ErrInvalidHeader
+	}
+
+	*l = Link{
+		IconIndex:   h.IconIndex,
+		ShowCommand: ShowCommand(h.ShowCommand),
+	}
+	r := &reader{data: data, pos: headerSize}
+
+	if h.LinkFlags&hasLinkTargetIDList != 0 {
+		size := r.uint16()
+		r.skip(int(size))
+	}
+	if h.LinkFlags&hasLinkInfo != 0 {
+		start := r.pos
+		size := r.uint32()
+		if r.err == nil {
+			l.Target = unmarshalLinkInfo(r.slice(start, int(size)))
+		}
+		r.pos = start + int(size)
+	}
+
+	for _, s := range []struct {
+		flag  uint32
+		value *string
+	}{
+		{hasName, &l.Description},
+		{hasRelativePath, &l.RelativePath},
+		{hasWorkingDir, &l.WorkingDir},
+		{hasArguments, &l.Arguments},
+		{hasIconLocation, &l.IconLocation},
+	} {
+		if h.LinkFlags&s.flag == 0 {
+			continue
+		}
+		count := int(r.uint16())
+		if h.LinkFlags&isUnicode != 0 {
+			*s.value = decodeUTF16(r.bytes(count * 2))
+		} else {
+			*s.value = string(r.bytes(count))
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	// Extra data blocks, up to the terminal block
+	for len(data)-r.pos >= 8 {
+		start := r.pos
+		size := int(r.uint32())
+		if size < 8 {
+			break
+		}
+		block := r.slice(start, size)
+		if r.err != nil {
+			return r.err
+		}
+		l.unmarshalExtraData(block, h.LinkFlags)
+		r.pos = start + size
+	}
+
+	return r.err
+}
+
+// Read reads a link from r.
+func Read(r io.Reader) (*Link, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l := &Link{}
+	if err := l.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ReadFile reads the link stored in the named file.
+func ReadFile(name string) (*Link, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	l := &Link{}
+	if err := l.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", name)
+	}
+	return l, nil
+}
+
+// WriteFile writes a link to the named file.
+func WriteFile(name string, l *Link, perm os.FileMode) error {
+	data, err := l.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, perm)
+}
+
+// marshalLinkInfo returns a LinkInfo structure pointing to a local path.
+func marshalLinkInfo(target string) []byte {
+	ansiTarget := append(toANSI(target), 0)
+	volumeID := make([]byte, volumeIDHeaderSize+1)
+	binary.LittleEndian.PutUint32(volumeID[0:], uint32(len(volumeID)))
+	binary.LittleEndian.PutUint32(volumeID[4:], driveFixed)
+	binary.LittleEndian.PutUint32(volumeID[12:], volumeIDHeaderSize)
+
+	volumeIDOffset := linkInfoHeaderSize
+	localBasePathOffset := volumeIDOffset + len(volumeID)
+	commonPathSuffixOffset := localBasePathOffset + len(ansiTarget)
+	localBasePathUnicodeOffset := commonPathSuffixOffset + 1
+	unicodeTarget := utf16.Encode([]rune(target + "\x00"))
+	commonPathSuffixUnicodeOffset := localBasePathUnicodeOffset + len(unicodeTarget)*2
+	size := commonPathSuffixUnicodeOffset + 2
+
+	var buf bytes.Buffer
+	for _, v := range []int{
+		size,
+		linkInfoHeaderSize,
+		volumeIDAndLocalBasePath,
+		volumeIDOffset,
+		localBasePathOffset,
+		0, // CommonNetworkRelativeLinkOffset
+		commonPathSuffixOffset,
+		localBasePathUnicodeOffset,
+		commonPathSuffixUnicodeOffset,
+	} {
+		writeUint32(&buf, uint32(v))
+	}
+	buf.Write(volumeID)
+	buf.Write(ansiTarget)
+	buf.WriteByte(0) // CommonPathSuffix
+	writeUTF16(&buf, unicodeTarget)
+	writeUint16(&buf, 0) // CommonPathSuffixUnicode
+
+	return buf.Bytes()
+}
+
+// unmarshalLinkInfo returns the local path of a LinkInfo structure, or an
+// empty string if it has none.
+func unmarshalLinkInfo(data []byte) string {
+	if len(data) < 0x1C {
+		return ""
+	}
+	headerSize := binary.LittleEndian.Uint32(data[4:])
+	flags := binary.LittleEndian.Uint32(data[8:])
+	if flags&volumeIDAndLocalBasePath == 0 {
+		return ""
+	}
+
+	if headerSize >= linkInfoHeaderSize && len(data) >= linkInfoHeaderSize {
+		base := utf16String(data, int(binary.LittleEndian.Uint32(data[0x1C:])))
+		suffix := utf16String(data, int(binary.LittleEndian.Uint32(data[0x20:])))
+		return base + suffix
+	}
+
+	base := ansiString(data, int(binary.LittleEndian.Uint32(data[0x10:])))
+	suffix := ansiString(data, int(binary.LittleEndian.Uint32(data[0x18:])))
+	return base + suffix
+}
+
+// toANSI returns s with non-ASCII characters replaced, the ANSI path is only
+// used by readers ignoring the Unicode one.
+func toANSI(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0x7F {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func ansiString(data []byte, offset int) string {
+	if offset <= 0 || offset >= len(data) {
+		return ""
+	}
+	end := bytes.IndexByte(data[offset:], 0)
+	if end < 0 {
+		return string(data[offset:])
+	}
+	return string(data[offset : offset+end])
+}
+
+func utf16String(data []byte, offset int) string {
+	if offset <= 0 || offset >= len(data) {
+		return ""
+	}
+	end := offset
+	for end+1 < len(data) && (data[end] != 0 || data[end+1] != 0) {
+		end += 2
+	}
+	return decodeUTF16(data[offset:end])
+}
+
+func decodeUTF16(b []byte) string {
+	chars := make([]uint16, len(b)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	buf.Write(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	buf.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func writeUTF16(buf *bytes.Buffer, chars []uint16) {
+	for _, c := range chars {
+		writeUint16(buf, c)
+	}
+}
+
+// reader reads little-endian values from data, recording the first error.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = ErrTruncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) slice(start int, size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if size < 0 || start+size > len(r.data) {
+		r.err = ErrTruncated
+		return nil
+	}
+	return r.data[start : start+size]
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
@@ -0,0 +1,199 @@
+package shelllink
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the testdata fixtures")
+
+var fixtures = []struct {
+	file string
+	link Link
+}{
+	{
+		file: "plain.lnk",
+		link: Link{
+			Target:       `C:\Portable\Floorp\app\floorp.exe`,
+			WorkingDir:   `C:\Portable\Floorp\app`,
+			Description:  "Floorp Portable",
+			IconLocation: `C:\Portable\Floorp\Floorp-Portable-v2.exe`,
+			ShowCommand:  ShowNormal,
+		},
+	},
+	{
+		file: "aumid.lnk",
+		link: Link{
+			Target:         `D:\Apps\Floorp-Portable-v2.exe`,
+			Arguments:      `--portable-profile work`,
+			WorkingDir:     `D:\Apps`,
+			Description:    "Floorp Portable (work)",
+			RelativePath:   `.\Floorp-Portable-v2.exe`,
+			IconLocation:   `D:\Apps\Floorp-Portable-v2.exe`,
+			IconIndex:      1,
+			ShowCommand:    ShowMaximized,
+			AppUserModelID: "Floorp.Portable.work",
+		},
+	},
+	{
+		file: "unicode.lnk",
+		link: Link{
+			Target:      `E:\Programme\Flörp 浏览器\floorp.exe`,
+			WorkingDir:  `E:\Programme\Flörp 浏览器`,
+			Description: "Flörp 🦊",
+			ShowCommand: ShowMinNoActive,
+		},
+	},
+}
+
+func TestFixtures(t *testing.T) {
+	for _, tt := range fixtures {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tt.file)
+			data, err := tt.link.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *update {
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fixture, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, fixture) {
+				t.Errorf("MarshalBinary differs from %s", path)
+			}
+
+			var link Link
+			if err := link.UnmarshalBinary(fixture); err != nil {
+				t.Fatal(err)
+			}
+			if link != tt.link {
+				t.Errorf("UnmarshalBinary = %+v, want %+v", link, tt.link)
+			}
+
+			roundTrip, err := link.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(roundTrip, fixture) {
+				t.Errorf("round trip of %s is not byte for byte identical", path)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	link, err := ReadFile(filepath.Join("testdata", "aumid.lnk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.AppUserModelID != "Floorp.Portable.work" {
+		t.Errorf("AppUserModelID = %q", link.AppUserModelID)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link.lnk")
+	want := fixtures[2].link
+	if err := WriteFile(path, &want, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Errorf("ReadFile = %+v, want %+v", *got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "plain.lnk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badCLSID := append([]byte{}, fixture...)
+	badCLSID[4] ^= 0xFF
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrInvalidHeader},
+		{"short header", fixture[:headerSize-1], ErrInvalidHeader},
+		{"bad clsid", badCLSID, ErrInvalidHeader},
+		{"truncated", fixture[:len(fixture)-20], ErrTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var link Link
+			if err := link.UnmarshalBinary(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("UnmarshalBinary = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestWindowsShortcut checks the package against Floorp.lnk, a Firefox
+// shortcut written by Windows itself (item ID list, LinkInfo without Unicode
+// paths, several property stores).
+func TestWindowsShortcut(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "Floorp.lnk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var link Link
+	if err := link.UnmarshalBinary(fixture); err != nil {
+		t.Fatal(err)
+	}
+	want := Link{
+		Target:         `C:\Program Files (x86)\Mozilla Firefox\firefox.exe`,
+		WorkingDir:     `C:\Program Files (x86)\Mozilla Firefox`,
+		RelativePath:   `..\..\..\Program Files (x86)\Mozilla Firefox\firefox.exe`,
+		ShowCommand:    ShowNormal,
+		AppUserModelID: "E7CF176E110C211B",
+	}
+	if link != want {
+		t.Errorf("UnmarshalBinary = %+v, want %+v", link, want)
+	}
+
+	data, err := link.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HeaderSize and LinkCLSID
+	if !bytes.Equal(data[:20], fixture[:20]) {
+		t.Errorf("header starts with % X, Windows wrote % X", data[:20], fixture[:20])
+	}
+	// ShowCommand
+	if !bytes.Equal(data[0x3C:0x40], fixture[0x3C:0x40]) {
+		t.Errorf("ShowCommand is % X, Windows wrote % X", data[0x3C:0x40], fixture[0x3C:0x40])
+	}
+
+	// RelativePath and WorkingDir are the only StringData of both links
+	stringData := data[headerSize+len(marshalLinkInfo(link.Target)):]
+	stringData = stringData[:2+2*len(link.RelativePath)+2+2*len(link.WorkingDir)]
+	if !bytes.Contains(fixture, stringData) {
+		t.Errorf("StringData % X not found in the shortcut written by Windows", stringData)
+	}
+
+	// The AppUserModelID value of the property store, from its ValueSize to
+	// its padding, is serialized the way Windows does
+	block := marshalPropertyStoreBlock(appUserModelIDKey, link.AppUserModelID)
+	value := block[8+24 : len(block)-8]
+	if !bytes.Contains(fixture, value) {
+		t.Errorf("AppUserModelID property % X not found in the shortcut written by Windows", value)
+	}
+}
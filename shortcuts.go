@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/shelllink"
+	"github.com/pkg/errors"
+	"github.com/portapps/portapps/v3/pkg/log"
+	"github.com/portapps/portapps/v3/pkg/utl"
+	"golang.org/x/sys/windows"
+)
+
+// Shortcut modes.
+const (
+	shortcutModeOff        = "off"
+	shortcutModeSession    = "session"
+	shortcutModePersistent = "persistent"
+)
+
+// Shortcut locations.
+const (
+	shortcutLocationDesktop     = "desktop"
+	shortcutLocationStartMenu   = "start_menu"
+	shortcutLocationQuickLaunch = "quick_launch"
+)
+
+// invalidFileNameChars cannot be used in Windows file names.
+const invalidFileNameChars = `\/:*?"<>|`
+
+var shortcutFolders = map[string]*windows.KNOWNFOLDERID{
+	shortcutLocationDesktop:     windows.FOLDERID_Desktop,
+	shortcutLocationStartMenu:   windows.FOLDERID_Programs,
+	shortcutLocationQuickLaunch: windows.FOLDERID_QuickLaunch,
+}
+
+// shortcutConfig holds the shortcuts settings. In session mode shortcuts are
+// removed when Floorp exits, in persistent mode they are kept. With PerProfile,
+// one shortcut to the launcher is created for each profile.
+type shortcutConfig struct {
+	Mode       string   `yaml:"mode" mapstructure:"mode"`
+	Name       string   `yaml:"name" mapstructure:"name"`
+	Locations  []string `yaml:"locations" mapstructure:"locations"`
+	PerProfile bool     `yaml:"per_profile" mapstructure:"per_profile"`
+}
+
+// shortcutFile is a shortcut to create.
+type shortcutFile struct {
+	Path string
+	Link *shelllink.Link
+}
+
+// createShortcuts creates the configured shortcuts and removes the ones
+// created by previous launches that are not configured anymore. It returns
+// the shortcuts to remove when Floorp exits.
+//
+// Shortcuts created by the launcher are tracked with their hash in the data
+// folder. A shortcut that is not tracked or has been modified belongs to the
+// user and is never overwritten nor removed.
+func createShortcuts() []string {
+	stateFile := utl.PathJoin(app.DataPath, "shortcuts.json")
+	owned, err := readShortcutsState(stateFile)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot load shortcuts state")
+		return nil
+	}
+
+	wanted, err := shortcutFiles(cfg.Shortcut)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid shortcut configuration")
+		return nil
+	}
+
+	configured := map[string]bool{}
+	for _, file := range wanted {
+		configured[file.Path] = true
+	}
+	for shortcutPath := range owned {
+		if !configured[shortcutPath] {
+			removeOwnedShortcut(shortcutPath, owned)
+		}
+	}
+
+	var created []string
+	for _, file := range wanted {
+		shortcutPath := file.Path
+		if utl.Exists(shortcutPath) && !isOwnedShortcut(shortcutPath, owned) {
+			log.Warn().Msgf("Shortcut %s already exists and is not managed by the launcher, skipping", shortcutPath)
+			delete(owned, shortcutPath)
+			continue
+		}
+
+		if err := shelllink.WriteFile(shortcutPath, file.Link, 0644); err != nil {
+			log.Error().Err(err).Msgf("Cannot create shortcut %s", shortcutPath)
+			continue
+		}
+		hash, err := fileSHA256(shortcutPath)
+		if err != nil {
+			log.Error().Err(err).Msgf("Cannot hash shortcut %s", shortcutPath)
+			continue
+		}
+		owned[shortcutPath] = hash
+		created = append(created, shortcutPath)
+	}
+
+	if err := writeShortcutsState(stateFile, owned); err != nil {
+		log.Error().Err(err).Msg("Cannot save shortcuts state")
+	}
+
+	if cfg.Shortcut.Mode != shortcutModeSession {
+		return nil
+	}
+	return created
+}
+
+// removeShortcuts removes shortcuts created by the launcher, unless they have
+// been modified since.
+func removeShortcuts(shortcutPaths []string) {
+	if len(shortcutPaths) == 0 {
+		return
+	}
+
+	stateFile := utl.PathJoin(app.DataPath, "shortcuts.json")
+	owned, err := readShortcutsState(stateFile)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot load shortcuts state")
+		return
+	}
+
+	for _, shortcutPath := range shortcutPaths {
+		removeOwnedShortcut(shortcutPath, owned)
+	}
+
+	if err := writeShortcutsState(stateFile, owned); err != nil {
+		log.Error().Err(err).Msg("Cannot save shortcuts state")
+	}
+}
+
+// removeOwnedShortcut removes a tracked shortcut if it is unchanged and stops
+// tracking it.
+func removeOwnedShortcut(shortcutPath string, owned map[string]string) {
+	defer delete(owned, shortcutPath)
+	if !utl.Exists(shortcutPath) {
+		return
+	}
+	if !isOwnedShortcut(shortcutPath, owned) {
+		log.Warn().Msgf("Shortcut %s has been modified, keeping it", shortcutPath)
+		return
+	}
+
+	log.Info().Msgf("Removing shortcut %s", shortcutPath)
+	if err := os.Remove(shortcutPath); err != nil {
+		log.Error().Err(err).Msg("Cannot remove shortcut")
+	}
+}
+
+// isOwnedShortcut reports whether shortcutPath was created by the launcher
+// and left unchanged.
+func isOwnedShortcut(shortcutPath string, owned map[string]string) bool {
+	hash, ok := owned[shortcutPath]
+	if !ok {
+		return false
+	}
+	current, err := fileSHA256(shortcutPath)
+	return err == nil && current == hash
+}
+
+// shortcutFiles returns the shortcuts of the configuration.
+func shortcutFiles(config shortcutConfig) ([]shortcutFile, error) {
+	switch config.Mode {
+	case shortcutModeOff:
+		return nil, nil
+	case shortcutModeSession, shortcutModePersistent:
+	default:
+		return nil, errors.Errorf("unknown mode %s", config.Mode)
+	}
+
+	name := strings.TrimSuffix(config.Name, ".lnk")
+	if name == "" || strings.ContainsAny(name, invalidFileNameChars) {
+		return nil, errors.Errorf("invalid name %q", config.Name)
+	}
+
+	links, err := shortcutLinks(name, config.PerProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []shortcutFile
+	for _, location := range config.Locations {
+		folderID, ok := shortcutFolders[strings.ToLower(location)]
+		if !ok {
+			return nil, errors.Errorf("unknown location %s", location)
+		}
+		folder, err := windows.KnownFolderPath(folderID, windows.KF_FLAG_DEFAULT)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot find %s folder", location)
+		}
+		for linkName, link := range links {
+			files = append(files, shortcutFile{
+				Path: filepath.Join(folder, linkName+".lnk"),
+				Link: link,
+			})
+		}
+	}
+
+	return files, nil
+}
+
+// shortcutLinks returns the links to create by shortcut name: one to Floorp, or
+// one to the launcher for each profile.
+func shortcutLinks(name string, perProfile bool) (map[string]*shelllink.Link, error) {
+	if !perProfile {
+		return map[string]*shelllink.Link{
+			name: {
+				Target:       app.Process,
+				Description:  name,
+				IconLocation: app.Process,
+				WorkingDir:   app.AppPath,
+			},
+		}, nil
+	}
+
+	launcher, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find launcher executable")
+	}
+	profiles, err := listProfiles()
+	if err != nil {
+		return nil, err
+	}
+	links := map[string]*shelllink.Link{}
+	for _, profile := range profiles {
+		profileName := fmt.Sprintf("%s (%s)", name, profile)
+		links[profileName] = &shelllink.Link{
+			Target:         launcher,
+			Arguments:      "--portable-profile " + syscall.EscapeArg(profile),
+			Description:    profileName,
+			IconLocation:   app.Process,
+			WorkingDir:     filepath.Dir(launcher),
+			AppUserModelID: profileAppUserModelID(utl.PathJoin(app.DataPath, "profile", profile)),
+		}
+	}
+	return links, nil
+}
+
+// listProfiles returns the profiles of the data folder.
+func listProfiles() ([]string, error) {
+	entries, err := os.ReadDir(utl.PathJoin(app.DataPath, "profile"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list profiles")
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	return profiles, nil
+}
+
+// profileAppUserModelID returns the AppUserModelID Floorp gives to the windows
+// of a profile when taskbar.grouping.useprofile is enabled: the decimal
+// mozilla::HashString of the UTF-8 profile path.
+func profileAppUserModelID(profileFolder string) string {
+	const goldenRatio = 0x9E3779B9
+
+	var hash uint32
+	for _, c := range []byte(profileFolder) {
+		// HashString reads the path as unsigned char
+		hash = goldenRatio * (bits.RotateLeft32(hash, 5) ^ uint32(c))
+	}
+	return strconv.FormatUint(uint64(hash), 10)
+}
+
+func readShortcutsState(filename string) (map[string]string, error) {
+	owned := map[string]string{}
+	if !utl.Exists(filename) {
+		return owned, nil
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read %s", filename)
+	}
+	if err := json.Unmarshal(raw, &owned); err != nil {
+		return nil, errors.Wrapf(err, "Cannot parse %s", filename)
+	}
+
+	return owned, nil
+}
+
+func writeShortcutsState(filename string, owned map[string]string) error {
+	if len(owned) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(owned, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, raw, 0644)
+}
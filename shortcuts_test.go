@@ -0,0 +1,244 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Floorp-Projects/Floorp-Portable-v2/shelllink"
+	"github.com/portapps/portapps/v3/pkg/utl"
+	"golang.org/x/sys/windows"
+)
+
+func TestProfileAppUserModelID(t *testing.T) {
+	// Computed with mfbt HashString(const char*, size_t), which reads bytes as
+	// unsigned char, over the UTF-8 path Floorp gets from the persistent
+	// descriptor of the profile folder. The AppUserModelID E7CF176E110C211B of Firefox shortcuts is the installer hash
+	// of the install path, which Floorp uses when grouping by profile is off.
+	tests := []struct {
+		profileFolder string
+		want          string
+	}{
+		{``, "0"},
+		{`D:\Floorp\data\profile\default`, "4069794674"},
+		{`C:\Users\me\Floorp-Portable\data\profile\work`, "1875951323"},
+		{`E:\Données\Floorp\data\profile\default`, "2204359802"},
+	}
+	for _, tt := range tests {
+		if got := profileAppUserModelID(tt.profileFolder); got != tt.want {
+			t.Errorf("profileAppUserModelID(%q) = %s, want %s", tt.profileFolder, got, tt.want)
+		}
+	}
+}
+
+func TestShortcutFiles(t *testing.T) {
+	saved := *app
+	t.Cleanup(func() {
+		*app = saved
+	})
+	app.DataPath = t.TempDir()
+	app.AppPath = filepath.Join(app.DataPath, "app")
+	app.Process = filepath.Join(app.AppPath, "floorp.exe")
+	mkdirs(t, filepath.Join(app.DataPath, "profile", "default"), filepath.Join(app.DataPath, "profile", "my work"))
+
+	desktop, err := windows.KnownFolderPath(windows.FOLDERID_Desktop, windows.KF_FLAG_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  shortcutConfig
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "off",
+			config: shortcutConfig{Mode: shortcutModeOff, Name: "Floorp", Locations: []string{"nowhere"}},
+		},
+		{
+			name:    "unknown mode",
+			config:  shortcutConfig{Mode: "always", Name: "Floorp"},
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			config:  shortcutConfig{Mode: shortcutModeSession, Name: ".lnk"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			config:  shortcutConfig{Mode: shortcutModeSession, Name: "Floorp: work"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown location",
+			config:  shortcutConfig{Mode: shortcutModeSession, Name: "Floorp", Locations: []string{"taskbar"}},
+			wantErr: true,
+		},
+		{
+			name:   "single",
+			config: shortcutConfig{Mode: shortcutModePersistent, Name: "Floorp.lnk", Locations: []string{"Desktop"}},
+			want:   []string{filepath.Join(desktop, "Floorp.lnk")},
+		},
+		{
+			name:   "per profile",
+			config: shortcutConfig{Mode: shortcutModeSession, Name: "Floorp", Locations: []string{shortcutLocationDesktop}, PerProfile: true},
+			want:   []string{filepath.Join(desktop, "Floorp (default).lnk"), filepath.Join(desktop, "Floorp (my work).lnk")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := shortcutFiles(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("shortcutFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, file := range files {
+				got = append(got, file.Path)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shortcutFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortcutLinks(t *testing.T) {
+	saved := *app
+	t.Cleanup(func() {
+		*app = saved
+	})
+	app.DataPath = t.TempDir()
+	app.AppPath = filepath.Join(app.DataPath, "app")
+	app.Process = filepath.Join(app.AppPath, "floorp.exe")
+	mkdirs(t, filepath.Join(app.DataPath, "profile", "my work"))
+
+	links, err := shortcutLinks("Floorp", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*shelllink.Link{
+		"Floorp": {Target: app.Process, Description: "Floorp", IconLocation: app.Process, WorkingDir: app.AppPath},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("shortcutLinks() = %v, want %v", links, want)
+	}
+
+	launcher, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err = shortcutLinks("Floorp", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]*shelllink.Link{
+		"Floorp (my work)": {
+			Target:         launcher,
+			Arguments:      `--portable-profile "my work"`,
+			Description:    "Floorp (my work)",
+			IconLocation:   app.Process,
+			WorkingDir:     filepath.Dir(launcher),
+			AppUserModelID: profileAppUserModelID(utl.PathJoin(app.DataPath, "profile", "my work")),
+		},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("shortcutLinks() per profile = %+v, want %+v", links["Floorp (my work)"], want["Floorp (my work)"])
+	}
+}
+func TestIsOwnedShortcut(t *testing.T) {
+	dir := t.TempDir()
+	unchanged := filepath.Join(dir, "unchanged.lnk")
+	modified := filepath.Join(dir, "modified.lnk")
+	untracked := filepath.Join(dir, "untracked.lnk")
+	removed := filepath.Join(dir, "removed.lnk")
+	writeFiles(t, map[string]string{unchanged: "link", modified: "link", untracked: "link", removed: "link"})
+
+	owned := map[string]string{}
+	for _, file := range []string{unchanged, modified, removed} {
+		hash, err := fileSHA256(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owned[file] = hash
+	}
+	writeFiles(t, map[string]string{modified: "edited link"})
+	if err := os.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{unchanged, true},
+		{modified, false},
+		{untracked, false},
+		{removed, false},
+	}
+	for _, tt := range tests {
+		if got := isOwnedShortcut(tt.path, owned); got != tt.want {
+			t.Errorf("isOwnedShortcut(%s) = %v, want %v", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestRemoveOwnedShortcut(t *testing.T) {
+	dir := t.TempDir()
+	unchanged := filepath.Join(dir, "unchanged.lnk")
+	modified := filepath.Join(dir, "modified.lnk")
+	removed := filepath.Join(dir, "removed.lnk")
+	writeFiles(t, map[string]string{unchanged: "link", modified: "link"})
+
+	owned := map[string]string{removed: "0000"}
+	for _, file := range []string{unchanged, modified} {
+		hash, err := fileSHA256(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owned[file] = hash
+	}
+	writeFiles(t, map[string]string{modified: "edited link"})
+
+	for _, file := range []string{unchanged, modified, removed} {
+		removeOwnedShortcut(file, owned)
+	}
+
+	if len(owned) != 0 {
+		t.Errorf("shortcuts still tracked: %v", owned)
+	}
+	if _, err := os.Stat(unchanged); !os.IsNotExist(err) {
+		t.Errorf("unchanged shortcut was not removed: %v", err)
+	}
+	if _, err := os.Stat(modified); err != nil {
+		t.Errorf("modified shortcut was removed: %v", err)
+	}
+}
+
+func TestShortcutsState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "shortcuts.json")
+	owned, err := readShortcutsState(stateFile)
+	if err != nil || len(owned) != 0 {
+		t.Fatalf("readShortcutsState() without state = %v, %v", owned, err)
+	}
+
+	want := map[string]string{`C:\Users\me\Desktop\Floorp.lnk`: "abcd"}
+	if err := writeShortcutsState(stateFile, want); err != nil {
+		t.Fatal(err)
+	}
+	if owned, err = readShortcutsState(stateFile); err != nil || !reflect.DeepEqual(owned, want) {
+		t.Errorf("readShortcutsState() = %v, %v, want %v", owned, err, want)
+	}
+
+	// No tracked shortcut left removes the state
+	if err := writeShortcutsState(stateFile, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("empty state was not removed: %v", err)
+	}
+}
@@ -6,6 +6,5 @@
 package tools
 
 import (
-	_ "github.com/kevinburke/go-bindata/v4"
 	_ "github.com/portapps/portapps/v3/tools"
 )